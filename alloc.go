@@ -0,0 +1,133 @@
+package saft
+
+import "strings"
+
+// allocator allocates parsed elements, either one by one or in blocks if
+// compact allocation is enabled.
+type allocator struct {
+	compact bool
+	pack    bool            // Pack string contents in blocks?
+	text    strings.Builder // Block of string contents
+	strings []String
+	lists   []List
+	assocs  []Assoc
+	elems   []Elem
+	pairs   []Pair
+}
+
+// Number of values allocated at a time in compact mode.
+const allocBlockSize = 256
+
+// newAllocator returns an allocator. String contents are packed in blocks in
+// compact mode if packStrings is set, which is pointless for strings already
+// referring to a shared input.
+func newAllocator(opts *ParseOptions, packStrings bool) allocator {
+	return allocator{compact: opts.Compact, pack: opts.Compact && packStrings}
+}
+
+// str returns s, copied to a block in compact mode. Strings are sliced from a
+// builder that is only appended to within its capacity, so the strings already
+// handed out are never modified.
+func (a *allocator) str(s string) string {
+	if !a.pack || s == "" || len(s) > allocBlockSize {
+		return s
+	}
+	if a.text.Cap()-a.text.Len() < len(s) {
+		a.text = strings.Builder{}
+		a.text.Grow(allocBlockSize * 16)
+	}
+	start := a.text.Len()
+	a.text.WriteString(s)
+	return a.text.String()[start:]
+}
+
+func (a *allocator) newString() *String {
+	if !a.compact {
+		return &String{}
+	}
+	if len(a.strings) == 0 {
+		a.strings = make([]String, allocBlockSize)
+	}
+	s := &a.strings[0]
+	a.strings = a.strings[1:]
+	return s
+}
+
+func (a *allocator) newList() *List {
+	if !a.compact {
+		return &List{}
+	}
+	if len(a.lists) == 0 {
+		a.lists = make([]List, allocBlockSize)
+	}
+	l := &a.lists[0]
+	a.lists = a.lists[1:]
+	return l
+}
+
+func (a *allocator) newAssoc() *Assoc {
+	if !a.compact {
+		return &Assoc{}
+	}
+	if len(a.assocs) == 0 {
+		a.assocs = make([]Assoc, allocBlockSize)
+	}
+	assoc := &a.assocs[0]
+	a.assocs = a.assocs[1:]
+	return assoc
+}
+
+// elemSlice returns a copy of elems. The capacity of slices allocated from a
+// block is limited so that appending to them reallocates rather than
+// overwriting the following slice.
+func (a *allocator) elemSlice(elems []Elem) []Elem {
+	n := len(elems)
+	if n == 0 {
+		return nil
+	}
+	if !a.compact || n > allocBlockSize/4 {
+		return append(make([]Elem, 0, n), elems...)
+	}
+	if len(a.elems) < n {
+		a.elems = make([]Elem, allocBlockSize)
+	}
+	s := a.elems[:n:n]
+	a.elems = a.elems[n:]
+	copy(s, elems)
+	return s
+}
+
+// pairSlice is like elemSlice but for pairs.
+func (a *allocator) pairSlice(pairs []Pair) Pairs {
+	n := len(pairs)
+	if n == 0 {
+		return nil
+	}
+	if !a.compact || n > allocBlockSize/4 {
+		return append(make([]Pair, 0, n), pairs...)
+	}
+	if len(a.pairs) < n {
+		a.pairs = make([]Pair, allocBlockSize)
+	}
+	s := a.pairs[:n:n]
+	a.pairs = a.pairs[n:]
+	copy(s, pairs)
+	return s
+}
+
+// str returns the content of a parsed string. A previously seen string equal
+// to s is returned if intern is set.
+func (p *parser) str(s string, intern bool) string {
+	if !intern {
+		return p.alloc.str(s)
+	}
+	if v, ok := p.strs[s]; ok {
+		return v
+	}
+	if p.strs == nil {
+		p.strs = make(map[string]string)
+	}
+	s = p.alloc.str(s)
+	p.strs[s] = s
+	return s
+}
@@ -0,0 +1,194 @@
+package saft
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// AnyMap is an association list decoded by DecodeAny. Pairs are in the order of
+// the association list and keys may be duplicated.
+type AnyMap []AnyPair
+
+// AnyPair is a key value pair in an AnyMap.
+type AnyPair struct {
+	K string // Key
+	V any    // Value
+}
+
+// Get returns the value of the first pair with the specified key.
+// Returns false if no pair was found.
+func (m AnyMap) Get(key string) (any, bool) {
+	for i := range m {
+		if m[i].K == key {
+			return m[i].V, true
+		}
+	}
+	return nil, false
+}
+
+// DuplicateKeys is the policy for duplicate keys when decoding association
+// lists into maps.
+type DuplicateKeys int
+
+const (
+	DuplicateKeysError DuplicateKeys = iota // Duplicate keys are errors
+	DuplicateKeysFirst                      // The first pair of a key is used
+	DuplicateKeysLast                       // The last pair of a key is used
+)
+
+// AnyOptions control how DecodeAnyWithOptions decodes elements.
+type AnyOptions struct {
+	Maps       bool          // Decode association lists into map[string]any rather than AnyMap
+	Duplicates DuplicateKeys // Policy for duplicate keys when Maps is set
+}
+
+// DecodeAny decodes the element e into a string, []any or AnyMap for strings,
+// lists and association lists respectively. Absent elements are decoded into
+// nil.
+func DecodeAny(e Elem) any {
+	v, _ := DecodeAnyWithOptions(e, AnyOptions{})
+	return v
+}
+
+// DecodeAnyWithOptions is like DecodeAny but takes options controlling how
+// association lists are decoded.
+// Returns an error containing positional information for duplicate keys.
+func DecodeAnyWithOptions(e Elem, opts AnyOptions) (any, error) {
+	switch x := e.any.(type) {
+	case *String:
+		return x.V, nil
+	case *List:
+		l := make([]any, len(x.L))
+		for i := range x.L {
+			v, err := DecodeAnyWithOptions(x.L[i], opts)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = v
+		}
+		return l, nil
+	case *Assoc:
+		if !opts.Maps {
+			m := make(AnyMap, len(x.L))
+			for i := range x.L {
+				v, _ := DecodeAnyWithOptions(x.L[i].V, opts)
+				m[i] = AnyPair{K: x.L[i].K.V, V: v}
+			}
+			return m, nil
+		}
+		m := make(map[string]any, len(x.L))
+		for i := range x.L {
+			key := &x.L[i].K
+			if _, ok := m[key.V]; ok {
+				switch opts.Duplicates {
+				case DuplicateKeysError:
+					return nil, fmt.Errorf("%s: duplicate key %q", key.where(), key.V)
+				case DuplicateKeysFirst:
+					continue
+				}
+			}
+			v, err := DecodeAnyWithOptions(x.L[i].V, opts)
+			if err != nil {
+				return nil, err
+			}
+			m[key.V] = v
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// EncodeAny encodes v into an element, reversing DecodeAny.
+//
+// Strings, booleans, numbers and values of types supported by Decode that are
+// decoded from strings are encoded into strings. Slices and arrays are encoded
+// into lists. AnyMap values and maps with string keys are encoded into
+// association lists, the latter with keys sorted. Elements are encoded as is.
+// Pointers and interfaces are followed. Nil, including nil pointers and
+// interfaces, is encoded into an absent element as DecodeAny decodes absent
+// elements into nil. Returns an error for values of other types.
+//
+// Byte slices are encoded into lists of numbers like other slices, matching how
+// Decode decodes them. Encode binary data into a string, such as base64, to
+// have it read by String.Base64.
+func EncodeAny(v any) (Elem, error) {
+	return encodeValue(reflect.ValueOf(v))
+}
+
+var anyMapType = reflect.TypeOf(AnyMap{})
+
+func encodeValue(rv reflect.Value) (Elem, error) {
+	for rv.IsValid() && (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return Elem{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return Elem{}, nil
+	}
+
+	t := rv.Type()
+	switch {
+	case t == elemType:
+		return rv.Interface().(Elem), nil
+	case t == anyMapType:
+		m := rv.Interface().(AnyMap)
+		assoc := &Assoc{L: make(Pairs, 0, len(m))}
+		for i := range m {
+			e, err := EncodeAny(m[i].V)
+			if err != nil {
+				return Elem{}, err
+			}
+			assoc.L.Add(m[i].K, e)
+		}
+		return Elem{assoc}, nil
+	case decodedFromString(t) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return Elem{NewString(formatString(rv))}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := &List{L: make([]Elem, rv.Len())}
+		for i := range list.L {
+			e, err := encodeValue(rv.Index(i))
+			if err != nil {
+				return Elem{}, err
+			}
+			list.L[i] = e
+		}
+		return Elem{list}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		assoc := &Assoc{L: make(Pairs, 0, len(keys))}
+		for _, k := range keys {
+			e, err := encodeValue(rv.MapIndex(k))
+			if err != nil {
+				return Elem{}, err
+			}
+			assoc.L.Add(k.String(), e)
+		}
+		return Elem{assoc}, nil
+	}
+	return Elem{}, fmt.Errorf("saft: cannot encode value of type %s", t)
+}
+
+// MarshalAny encodes v into a Saft document as described by EncodeAny.
+// Association lists are written as indented blocks. Absent elements are
+// omitted, resulting in an empty document if v is nil.
+func MarshalAny(v any) ([]byte, error) {
+	e, err := EncodeAny(v)
+	if err != nil || e.IsZero() {
+		return nil, err
+	}
+	var sb strings.Builder
+	writeElem(&sb, e, 0)
+	sb.WriteByte('\n')
+	return []byte(sb.String()), nil
+}
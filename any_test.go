@@ -0,0 +1,108 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeAny(t *testing.T) {
+	e := getTestElem(t, `{a: b c: [d {e: f}] a: g}`)
+	want := saft.AnyMap{
+		{K: "a", V: "b"},
+		{K: "c", V: []any{"d", saft.AnyMap{{K: "e", V: "f"}}}},
+		{K: "a", V: "g"},
+	}
+	got := saft.DecodeAny(e)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("saft.DecodeAny() = %#v; want %#v", got, want)
+	}
+	if v, ok := want.Get("a"); v != "b" || !ok {
+		t.Fatalf(`AnyMap.Get("a") = (%v, %v); want (b, true)`, v, ok)
+	}
+	if got := saft.DecodeAny(saft.Elem{}); got != nil {
+		t.Fatalf("saft.DecodeAny(saft.Elem{}) = %#v; want nil", got)
+	}
+}
+
+func TestDecodeAnyWithOptions(t *testing.T) {
+	e := getTestElem(t, `{a: b c: [{e: f}] a: g}`)
+	testData := []struct {
+		duplicates saft.DuplicateKeys
+		want       any
+		err        string
+	}{
+		{saft.DuplicateKeysError, nil, `1:18: duplicate key "a"`},
+		{saft.DuplicateKeysFirst, map[string]any{"a": "b", "c": []any{map[string]any{"e": "f"}}}, "nil"},
+		{saft.DuplicateKeysLast, map[string]any{"a": "g", "c": []any{map[string]any{"e": "f"}}}, "nil"},
+	}
+	for _, td := range testData {
+		got, err := saft.DecodeAnyWithOptions(e, saft.AnyOptions{Maps: true, Duplicates: td.duplicates})
+		checkError(t, "saft.DecodeAnyWithOptions()", err, td.err)
+		if !reflect.DeepEqual(got, td.want) {
+			t.Fatalf("saft.DecodeAnyWithOptions() = %#v; want %#v", got, td.want)
+		}
+	}
+}
+
+func TestEncodeAny(t *testing.T) {
+	v := saft.AnyMap{
+		{K: "a", V: "b"},
+		{K: "c", V: []any{1, true, 2.5, time.Second}},
+		{K: "a", V: map[string]any{"y": "z", "x": []string{"w"}}},
+		{K: "e", V: getTestElem(t, `[f]`)},
+	}
+	e, err := saft.EncodeAny(v)
+	if err != nil {
+		t.Fatalf("saft.EncodeAny() error = %q", err)
+	}
+	want := `{"a":"b"  "c":["1" "true" "2.5" "1s" ] "a":{"x":["w" ] "y":"z"  } "e":["f" ] } `
+	if got := elemsToString([]saft.Elem{e}); got != want {
+		t.Fatalf("saft.EncodeAny() = %s; want %s", got, want)
+	}
+
+	if got := saft.DecodeAny(e); !reflect.DeepEqual(got, saft.DecodeAny(getTestElem(t, `{a: b c: [1 true 2.5 1s] a: {x: [w] y: z} e: [f]}`))) {
+		t.Fatalf("saft.DecodeAny(saft.EncodeAny()) = %#v", got)
+	}
+
+	// Nil is encoded into absent elements, reversing DecodeAny.
+	e, err = saft.EncodeAny(nil)
+	if !e.IsZero() || err != nil {
+		t.Fatalf("saft.EncodeAny(nil) = (%v, %q); want absent element", e.Kind(), errorString(err))
+	}
+	e, err = saft.EncodeAny([]any{"a", nil, (*int)(nil)})
+	checkError(t, "saft.EncodeAny()", err, "nil")
+	if got := saft.DecodeAny(e); !reflect.DeepEqual(got, []any{"a", nil, nil}) {
+		t.Fatalf("saft.DecodeAny(saft.EncodeAny()) = %#v", got)
+	}
+
+	// Byte slices are lists of numbers, as decoded by saft.Decode.
+	e, err = saft.EncodeAny([]byte("hi"))
+	checkError(t, "saft.EncodeAny()", err, "nil")
+	checkElems(t, []saft.Elem{e}, `["104" "105" ]`)
+	var b []byte
+	checkError(t, "saft.Decode()", saft.Decode(e, &b), "nil")
+	if string(b) != "hi" {
+		t.Fatalf("saft.Decode(saft.EncodeAny()) = %q; want %q", b, "hi")
+	}
+
+	_, err = saft.EncodeAny(map[int]string{})
+	checkError(t, "saft.EncodeAny()", err, "saft: cannot encode value of type map[int]string")
+}
+
+func TestMarshalAny(t *testing.T) {
+	data, err := saft.MarshalAny(saft.AnyMap{
+		{K: "a b", V: "c"},
+		{K: "d", V: saft.AnyMap{{K: "e", V: []any{"f", ""}}}},
+	})
+	want := "{\n  \"a b\": c\n  d: {\n    e: [f \"\"]\n  }\n}\n"
+	if string(data) != want || err != nil {
+		t.Fatalf("saft.MarshalAny() = (%q, %q); want (%q, nil)", data, errorString(err), want)
+	}
+
+	data, err = saft.MarshalAny(nil)
+	if len(data) != 0 || err != nil {
+		t.Fatalf("saft.MarshalAny(nil) = (%q, %q); want empty document", data, errorString(err))
+	}
+}
@@ -3,7 +3,13 @@ package saft
 // Assoc is an association list.
 type Assoc struct {
 	pos LexPos
-	L   Pairs // Key value pairs.
+	src *source // Origin of an association list not parsed from a document or nil
+	L   Pairs   // Key value pairs.
+}
+
+// NewAssoc returns a new association list containing pairs.
+func NewAssoc(pairs ...Pair) *Assoc {
+	return &Assoc{L: pairs}
 }
 
 // Pos returns positional information useful for context dependent error reporting.
@@ -11,8 +17,19 @@ func (al *Assoc) Pos() LexPos {
 	return al.pos
 }
 
-func (al *Assoc) elemType() elemType {
-	return elemTypeAssoc
+// Origin describes where the association list came from if it was not parsed
+// from a document, such as the environment variable of an overridden setting
+// it was created for. See String.Origin.
+func (al *Assoc) Origin() string {
+	return al.src.originOf()
+}
+
+func (al *Assoc) where() string {
+	return al.src.where(&al.pos)
+}
+
+func (al *Assoc) kind() Kind {
+	return KindAssoc
 }
 
 // Pairs is the list of pairs in an association list.
@@ -24,6 +41,11 @@ type Pair struct {
 	V Elem   // Value
 }
 
+// NewPair returns a new pair with the specified key and value.
+func NewPair(key string, v Elem) Pair {
+	return Pair{K: String{V: key}, V: v}
+}
+
 // Find first pair in list of pairs with the specified key.
 // Returns a list cut so that the found pair is first or nil if no pair was found.
 func (lst Pairs) Find(key string) Pairs {
@@ -40,3 +62,105 @@ func (lst Pairs) FindP(pred func(key string) bool) Pairs {
 	}
 	return nil
 }
+
+// index returns the index of the first pair with the specified key or -1 if no
+// pair was found.
+func (lst Pairs) index(key string) int {
+	for i := range lst {
+		if lst[i].K.V == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// maxScannedPairs is the length of association lists up to which duplicate
+// keys are found by scanning rather than by a map.
+const maxScannedPairs = 8
+
+// duplicate returns the index of the first pair with the same key as a
+// previous pair or -1 if all keys are unique.
+func (lst Pairs) duplicate() int {
+	if len(lst) <= maxScannedPairs {
+		for i := range lst {
+			if lst.index(lst[i].K.V) != i {
+				return i
+			}
+		}
+		return -1
+	}
+	seen := make(map[string]struct{}, len(lst))
+	for i := range lst {
+		if _, ok := seen[lst[i].K.V]; ok {
+			return i
+		}
+		seen[lst[i].K.V] = struct{}{}
+	}
+	return -1
+}
+
+// Set the value of the first pair with the specified key.
+// A new pair is appended if no pair was found.
+func (lst *Pairs) Set(key string, v Elem) {
+	if i := lst.index(key); i >= 0 {
+		(*lst)[i].V = v
+	} else {
+		lst.Add(key, v)
+	}
+}
+
+// Add appends a new pair regardless of whether there are pairs with the same key.
+func (lst *Pairs) Add(key string, v Elem) {
+	*lst = append(*lst, NewPair(key, v))
+}
+
+// Insert a new pair before index i. The index may be equal to the length of the
+// list to append the pair. Returns false if the index is out of range.
+func (lst *Pairs) Insert(i int, key string, v Elem) bool {
+	if i < 0 || i > len(*lst) {
+		return false
+	}
+	*lst = append(*lst, Pair{})
+	copy((*lst)[i+1:], (*lst)[i:])
+	(*lst)[i] = NewPair(key, v)
+	return true
+}
+
+// Delete the first pair with the specified key.
+// Returns false if no pair was found.
+func (lst *Pairs) Delete(key string) bool {
+	i := lst.index(key)
+	if i < 0 {
+		return false
+	}
+	lst.deleteIndex(i)
+	return true
+}
+
+// DeleteAll deletes all pairs with the specified key.
+// Returns the number of deleted pairs.
+func (lst *Pairs) DeleteAll(key string) int {
+	kept := (*lst)[:0]
+	for _, pair := range *lst {
+		if pair.K.V != key {
+			kept = append(kept, pair)
+		}
+	}
+	n := len(*lst) - len(kept)
+	clearPairs((*lst)[len(kept):])
+	*lst = kept
+	return n
+}
+
+func (lst *Pairs) deleteIndex(i int) {
+	copy((*lst)[i:], (*lst)[i+1:])
+	clearPairs((*lst)[len(*lst)-1:])
+	*lst = (*lst)[:len(*lst)-1]
+}
+
+// clearPairs zeroes removed pairs so that their values are not kept reachable.
+func clearPairs(lst Pairs) {
+	for i := range lst {
+		lst[i] = Pair{}
+	}
+}
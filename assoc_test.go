@@ -1,8 +1,8 @@
 package saft_test
 
 import (
-	//	"github.com/johan-bolmsjo/saft"
 	"fmt"
+	"github.com/johan-bolmsjo/saft"
 	"strings"
 	"testing"
 )
@@ -39,3 +39,27 @@ func TestAssoc_Find(t *testing.T) {
 		t.Fatalf("found association list pairs:\n%s\nwant:\n%s\n", got, want)
 	}
 }
+
+func TestAssoc_Mutate(t *testing.T) {
+	assoc := saft.NewAssoc(saft.NewPair("a", saft.ElemOf(saft.NewString("1"))))
+	str := func(v string) saft.Elem { return saft.ElemOf(saft.NewString(v)) }
+
+	assoc.L.Set("a", str("2"))
+	assoc.L.Set("b", str("3"))
+	assoc.L.Add("a", str("4"))
+	if !assoc.L.Insert(0, "c", str("5")) {
+		t.Fatalf("assoc.L.Insert(0) = false")
+	}
+	if assoc.L.Insert(5, "c", str("5")) {
+		t.Fatalf("assoc.L.Insert(5) = true")
+	}
+	checkElems(t, []saft.Elem{saft.ElemOf(assoc)}, `{"c":"5"  "a":"2"  "b":"3"  "a":"4"  }`)
+
+	if !assoc.L.Delete("b") || assoc.L.Delete("x") {
+		t.Fatalf("assoc.L.Delete() returned unexpected result")
+	}
+	if n := assoc.L.DeleteAll("a"); n != 2 {
+		t.Fatalf("assoc.L.DeleteAll(\"a\") = %d; want 2", n)
+	}
+	checkElems(t, []saft.Elem{saft.ElemOf(assoc)}, `{"c":"5"  }`)
+}
@@ -0,0 +1,428 @@
+package saft
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Binary encoding of elements.
+//
+// The binary encoding is a compact representation of elements that is faster
+// to decode than text. It consists of a header, the root elements and an
+// optional table of positions. Integers are varints as encoded by
+// encoding/binary.
+//
+//	document  = header uvarint(n) elem*n [positions]
+//	header    = "saft" uvarint(version) byte(flags)
+//	elem      = 'n'                            // Absent element
+//	          | 's' string
+//	          | 'l' uvarint(n) elem*n
+//	          | 'a' uvarint(n) (string elem)*n // Key and value pairs
+//	string    = [byte(form)] uvarint(len) byte*len
+//	positions = uvarint(m) source*m uvarint(n) position*n
+//	source    = byte(tab width) byte(column unit) uvarint(len) byte*len // Origin
+//	            uvarint(k) uvarint(CR offset delta)*k
+//	position  = uvarint(source) varint(line delta) uvarint(column) varint(offset delta)
+//
+// The version is currently 1. Flag bit 0 is set if the positions table is
+// present and flag bit 1 if strings have forms. The positions table holds the
+// position of each string, list, association list and key in the order they
+// appear in the encoding, with lines and offsets relative to the preceding
+// position. Positions refer to the sources elements share by index plus one, or
+// zero for none. A source holds the column semantics and dropped CRs needed to
+// compute positions within strings, see String.PosAt, and the origin of
+// elements not parsed from a document, see String.Origin. A zero tab width
+// stands for strings not parsed from a document.
+const (
+	binaryMagic   = "saft"
+	binaryVersion = 1
+
+	binaryFlagPositions = 1 << 0
+	binaryFlagForms     = 1 << 1
+
+	binaryTagNone   = 'n'
+	binaryTagString = 's'
+	binaryTagList   = 'l'
+	binaryTagAssoc  = 'a'
+)
+
+// BinaryOptions control what optional information is included in the binary
+// encoding. Absent information is decoded as zero values.
+type BinaryOptions struct {
+	Positions bool // Include positions and origins of elements
+	Forms     bool // Include syntax forms of strings
+}
+
+// AppendBinary appends the binary encoding of elems to b.
+func AppendBinary(b []byte, elems []Elem, opts BinaryOptions) []byte {
+	var flags byte
+	if opts.Positions {
+		flags |= binaryFlagPositions
+	}
+	if opts.Forms {
+		flags |= binaryFlagForms
+	}
+
+	enc := binaryEncoder{b: b, opts: opts}
+	enc.b = append(enc.b, binaryMagic...)
+	enc.b = binary.AppendUvarint(enc.b, binaryVersion)
+	enc.b = append(enc.b, flags)
+	enc.b = binary.AppendUvarint(enc.b, uint64(len(elems)))
+	for i := range elems {
+		enc.elem(elems[i])
+	}
+
+	if opts.Positions {
+		enc.b = binary.AppendUvarint(enc.b, uint64(len(enc.sources)))
+		for _, src := range enc.sources {
+			enc.source(src)
+		}
+		enc.b = binary.AppendUvarint(enc.b, uint64(len(enc.positions)))
+		var prev LexPos
+		for i, pos := range enc.positions {
+			enc.b = binary.AppendUvarint(enc.b, uint64(enc.posSources[i]))
+			enc.b = binary.AppendVarint(enc.b, int64(pos.Line)-int64(prev.Line))
+			enc.b = binary.AppendUvarint(enc.b, uint64(uint32(pos.Column)))
+			enc.b = binary.AppendVarint(enc.b, int64(pos.Offset)-int64(prev.Offset))
+			prev = pos
+		}
+	}
+	return enc.b
+}
+
+// ParseBinary decodes elements encoded by AppendBinary.
+//
+// The data is copied once and decoded strings refer to the copy rather than
+// being allocated separately. Elements are allocated in blocks like with the
+// Compact parse option.
+func ParseBinary(data []byte) ([]Elem, error) {
+	dec := binaryDecoder{src: string(data), alloc: allocator{compact: true}}
+	elems := dec.document()
+	if dec.err != nil {
+		return nil, dec.err
+	}
+	return elems, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+// The element is encoded as a document of one root element, including
+// positions and string forms.
+func (e Elem) MarshalBinary() ([]byte, error) {
+	return AppendBinary(nil, []Elem{e}, BinaryOptions{Positions: true, Forms: true}), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// The data must be a document of exactly one root element.
+func (e *Elem) UnmarshalBinary(data []byte) error {
+	elems, err := ParseBinary(data)
+	if err != nil {
+		return err
+	}
+	if len(elems) != 1 {
+		return fmt.Errorf("binary encoding: expected 1 root element, found %d", len(elems))
+	}
+	*e = elems[0]
+	return nil
+}
+
+type binaryEncoder struct {
+	b          []byte
+	opts       BinaryOptions
+	positions  []LexPos
+	posSources []int // Source index plus one of each position or zero
+	sources    []*source
+	sourceIdx  map[*source]int // Index plus one of sources
+}
+
+func (enc *binaryEncoder) pos(pos LexPos, src *source) {
+	if !enc.opts.Positions {
+		return
+	}
+	i := 0
+	if src != nil {
+		if i = enc.sourceIdx[src]; i == 0 {
+			if enc.sourceIdx == nil {
+				enc.sourceIdx = make(map[*source]int)
+			}
+			enc.sources = append(enc.sources, src)
+			i = len(enc.sources)
+			enc.sourceIdx[src] = i
+		}
+	}
+	enc.positions = append(enc.positions, pos)
+	enc.posSources = append(enc.posSources, i)
+}
+
+func (enc *binaryEncoder) source(src *source) {
+	enc.b = append(enc.b, src.cols.tabWidth, byte(src.cols.unit))
+	enc.b = binary.AppendUvarint(enc.b, uint64(len(src.origin)))
+	enc.b = append(enc.b, src.origin...)
+	enc.b = binary.AppendUvarint(enc.b, uint64(len(src.crs)))
+	var prev int32
+	for _, offset := range src.crs {
+		enc.b = binary.AppendUvarint(enc.b, uint64(offset-prev))
+		prev = offset
+	}
+}
+
+func (enc *binaryEncoder) string(s *String) {
+	enc.pos(s.pos, s.src)
+	if enc.opts.Forms {
+		enc.b = append(enc.b, byte(s.form))
+	}
+	enc.b = binary.AppendUvarint(enc.b, uint64(len(s.V)))
+	enc.b = append(enc.b, s.V...)
+}
+
+func (enc *binaryEncoder) elem(e Elem) {
+	switch x := e.any.(type) {
+	case *String:
+		enc.b = append(enc.b, binaryTagString)
+		enc.string(x)
+	case *List:
+		enc.b = append(enc.b, binaryTagList)
+		enc.pos(x.pos, x.src)
+		enc.b = binary.AppendUvarint(enc.b, uint64(len(x.L)))
+		for i := range x.L {
+			enc.elem(x.L[i])
+		}
+	case *Assoc:
+		enc.b = append(enc.b, binaryTagAssoc)
+		enc.pos(x.pos, x.src)
+		enc.b = binary.AppendUvarint(enc.b, uint64(len(x.L)))
+		for i := range x.L {
+			enc.string(&x.L[i].K)
+			enc.elem(x.L[i].V)
+		}
+	default:
+		enc.b = append(enc.b, binaryTagNone)
+	}
+}
+
+// binaryDecoder decodes the binary encoding. The first error is recorded and
+// stops decoding.
+type binaryDecoder struct {
+	src       string
+	off       int
+	err       error
+	forms     bool
+	alloc     allocator
+	npos      int    // Number of positions in decoded elements
+	elemStack []Elem // Elements of lists being decoded
+	pairStack []Pair // Pairs of association lists being decoded
+}
+
+// Maximum nesting depth of decoded lists and association lists.
+const binaryMaxDepth = 10000
+
+func (dec *binaryDecoder) errorf(format string, a ...interface{}) {
+	if dec.err == nil {
+		dec.err = fmt.Errorf("binary encoding: offset %d: %s", dec.off, fmt.Sprintf(format, a...))
+	}
+}
+
+func (dec *binaryDecoder) document() []Elem {
+	if len(dec.src) < len(binaryMagic) || dec.src[:len(binaryMagic)] != binaryMagic {
+		dec.errorf("not a binary encoding")
+		return nil
+	}
+	dec.off = len(binaryMagic)
+	if version := dec.uvarint(); dec.err == nil && version != binaryVersion {
+		dec.errorf("unsupported version %d", version)
+	}
+	flags := dec.byte()
+	if dec.err == nil && flags&^(binaryFlagPositions|binaryFlagForms) != 0 {
+		dec.errorf("unknown flags %#x", flags)
+	}
+	dec.forms = flags&binaryFlagForms != 0
+
+	n := dec.count()
+	elems := make([]Elem, 0, n)
+	for i := 0; i < n && dec.err == nil; i++ {
+		elems = append(elems, dec.elem(1))
+	}
+
+	if flags&binaryFlagPositions != 0 {
+		dec.positionsTable(elems)
+	}
+	if dec.err == nil && dec.off != len(dec.src) {
+		dec.errorf("trailing data")
+	}
+	return elems
+}
+
+// positionsTable decodes the positions table and assigns the positions and
+// sources to elems in encoding order.
+func (dec *binaryDecoder) positionsTable(elems []Elem) {
+	sources := make([]*source, dec.count())
+	for i := 0; i < len(sources) && dec.err == nil; i++ {
+		sources[i] = dec.source()
+	}
+	if n := dec.count(); dec.err == nil && n != dec.npos {
+		dec.errorf("expected %d positions, found %d", dec.npos, n)
+	}
+	var prev LexPos
+	next := func(pos *LexPos, src **source) {
+		if i := dec.uvarint(); dec.err == nil && i > uint64(len(sources)) {
+			dec.errorf("invalid source %d", i)
+		} else if i > 0 {
+			*src = sources[i-1]
+		}
+		pos.Line = int32(int64(prev.Line) + dec.varint())
+		pos.Column = int32(dec.uvarint())
+		pos.Offset = int32(int64(prev.Offset) + dec.varint())
+		prev = *pos
+	}
+
+	var assign func(e Elem)
+	assign = func(e Elem) {
+		switch x := e.any.(type) {
+		case *String:
+			next(&x.pos, &x.src)
+		case *List:
+			next(&x.pos, &x.src)
+			for i := 0; i < len(x.L) && dec.err == nil; i++ {
+				assign(x.L[i])
+			}
+		case *Assoc:
+			next(&x.pos, &x.src)
+			for i := 0; i < len(x.L) && dec.err == nil; i++ {
+				next(&x.L[i].K.pos, &x.L[i].K.src)
+				assign(x.L[i].V)
+			}
+		}
+	}
+	for i := 0; i < len(elems) && dec.err == nil; i++ {
+		assign(elems[i])
+	}
+}
+
+func (dec *binaryDecoder) source() *source {
+	src := &source{}
+	src.cols.tabWidth = dec.byte()
+	if src.cols.unit = ColumnUnit(dec.byte()); dec.err == nil && (src.cols.unit < ColumnRunes || src.cols.unit > ColumnUTF16) {
+		dec.off--
+		dec.errorf("invalid column unit %d", src.cols.unit)
+	}
+	if n := dec.count(); dec.err == nil {
+		src.origin = dec.src[dec.off : dec.off+n]
+		dec.off += n
+	}
+	if n := dec.count(); dec.err == nil && n > 0 {
+		src.crs = make([]int32, n)
+		var prev int32
+		for i := range src.crs {
+			prev += int32(dec.uvarint())
+			src.crs[i] = prev
+		}
+	}
+	return src
+}
+
+func (dec *binaryDecoder) elem(depth int) Elem {
+	if depth > binaryMaxDepth {
+		dec.errorf("maximum nesting depth exceeded")
+		return Elem{}
+	}
+
+	switch tag := dec.byte(); tag {
+	case binaryTagNone:
+		return Elem{}
+	case binaryTagString:
+		s := dec.alloc.newString()
+		dec.string(s)
+		return Elem{s}
+	case binaryTagList:
+		list := dec.alloc.newList()
+		dec.npos++
+		mark := len(dec.elemStack)
+		n := dec.count()
+		for i := 0; i < n && dec.err == nil; i++ {
+			dec.elemStack = append(dec.elemStack, dec.elem(depth+1))
+		}
+		list.L = dec.alloc.elemSlice(dec.elemStack[mark:])
+		dec.elemStack = dec.elemStack[:mark]
+		return Elem{list}
+	case binaryTagAssoc:
+		assoc := dec.alloc.newAssoc()
+		dec.npos++
+		mark := len(dec.pairStack)
+		n := dec.count()
+		for i := 0; i < n && dec.err == nil; i++ {
+			var pair Pair
+			dec.string(&pair.K)
+			pair.V = dec.elem(depth + 1)
+			dec.pairStack = append(dec.pairStack, pair)
+		}
+		assoc.L = dec.alloc.pairSlice(dec.pairStack[mark:])
+		dec.pairStack = dec.pairStack[:mark]
+		return Elem{assoc}
+	default:
+		if dec.err == nil {
+			dec.off--
+			dec.errorf("invalid tag %#x", tag)
+		}
+		return Elem{}
+	}
+}
+
+func (dec *binaryDecoder) string(s *String) {
+	dec.npos++
+	if dec.forms {
+		s.form = StringForm(dec.byte())
+		if dec.err == nil && (s.form < FormUnspecified || s.form > FormRaw) {
+			dec.off--
+			dec.errorf("invalid string form %d", s.form)
+		}
+	}
+	n := dec.count()
+	if dec.err == nil {
+		s.V = dec.src[dec.off : dec.off+n]
+		dec.off += n
+	}
+}
+
+func (dec *binaryDecoder) byte() byte {
+	if dec.err != nil {
+		return 0
+	}
+	if dec.off >= len(dec.src) {
+		dec.errorf("unexpected end of data")
+		return 0
+	}
+	c := dec.src[dec.off]
+	dec.off++
+	return c
+}
+
+func (dec *binaryDecoder) uvarint() uint64 {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		c := dec.byte()
+		if dec.err != nil {
+			return 0
+		}
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v
+		}
+	}
+	dec.errorf("invalid varint")
+	return 0
+}
+
+func (dec *binaryDecoder) varint() int64 {
+	v := dec.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// count decodes a number of items following in the data. Every item takes at
+// least one byte, which limits the number to the size of the remaining data.
+func (dec *binaryDecoder) count() int {
+	v := dec.uvarint()
+	if dec.err == nil && v > uint64(len(dec.src)-dec.off) {
+		dec.errorf("count %d exceeds data size", v)
+		return 0
+	}
+	return int(v)
+}
@@ -0,0 +1,141 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const binaryTestInput = "a \"b\\tc\" [d `e` []] {f:{} g:[h] k:{l:m n:{o:p}} q:r}\n{\n  i: j\n}"
+
+func TestBinary_RoundTrip(t *testing.T) {
+	want, err := saft.Parse(strings.NewReader(binaryTestInput))
+	checkParseError(t, err, "nil")
+
+	for _, td := range []struct {
+		opts      saft.BinaryOptions
+		equalOpts saft.EqualOptions
+	}{
+		{saft.BinaryOptions{Positions: true, Forms: true}, saft.EqualOptions{}},
+		{saft.BinaryOptions{Positions: true}, saft.EqualOptions{IgnoreForm: true}},
+		{saft.BinaryOptions{Forms: true}, saft.EqualOptions{IgnorePos: true}},
+		{saft.BinaryOptions{}, saft.EqualOptions{IgnorePos: true, IgnoreForm: true}},
+	} {
+		data := saft.AppendBinary(nil, want, td.opts)
+		got, err := saft.ParseBinary(data)
+		checkError(t, "ParseBinary()", err, "nil")
+		if len(got) != len(want) {
+			t.Fatalf("%+v: got %d elems; want %d", td.opts, len(got), len(want))
+		}
+		for i := range got {
+			if !saft.Equal(got[i], want[i], td.equalOpts) {
+				t.Fatalf("%+v: got %s; want %s", td.opts, elemsToString(got), elemsToString(want))
+			}
+		}
+		if !td.opts.Positions && got[1].Pos() != (saft.LexPos{}) {
+			t.Fatalf("%+v: got position %v; want none", td.opts, got[1].Pos())
+		}
+	}
+}
+
+func TestBinary_RoundTripErrors(t *testing.T) {
+	// Errors of decoded elements must read like those of parsed elements,
+	// which depend on the options of the parse and on origins.
+	want, err := saft.ParseBytesWithOptions([]byte("{\n\ta: `ab\r\n\tcx`\n\tb: \"😀zz\"\n}"),
+		saft.ParseOptions{TabWidth: 4, Columns: saft.ColumnDisplay, NormalizeCRLF: true})
+	checkParseError(t, err, "nil")
+	assoc, _ := want[0].IsAssoc()
+	assoc.L.Add("c", saft.ElemOf(saft.NewStringOrigin("x", "env C")))
+
+	// Errors and end positions of the string values.
+	errorsOf := func(elems []saft.Elem) (errs []string) {
+		assoc, _ := elems[0].IsAssoc()
+		for _, pair := range assoc.L {
+			s, _ := pair.V.IsString()
+			_, err := s.Hex()
+			end := s.PosAt(len(s.V))
+			errs = append(errs, errorString(err), end.String()+" offset "+strconv.Itoa(int(end.Offset)))
+		}
+		return errs
+	}
+	wantErrs := errorsOf(want)
+	if wantErrs[0] != "3:5: invalid hex digit 'x'" {
+		t.Fatalf("unexpected error of parsed element %q", wantErrs[0])
+	}
+
+	data := saft.AppendBinary(nil, want, saft.BinaryOptions{Positions: true, Forms: true})
+	got, err := saft.ParseBinary(data)
+	checkError(t, "ParseBinary()", err, "nil")
+	for i, gotErr := range errorsOf(got) {
+		if gotErr != wantErrs[i] {
+			t.Errorf("decoded element: got %q; want %q", gotErr, wantErrs[i])
+		}
+	}
+}
+
+func TestElem_MarshalBinary(t *testing.T) {
+	elem := getTestElem(t, "{a:[b c]}")
+	data, err := elem.MarshalBinary()
+	checkError(t, "MarshalBinary()", err, "nil")
+
+	var got saft.Elem
+	checkError(t, "UnmarshalBinary()", got.UnmarshalBinary(data), "nil")
+	if !saft.Equal(got, elem, saft.EqualOptions{}) {
+		t.Fatalf("UnmarshalBinary() got %s; want %s", elemsToString([]saft.Elem{got}), elemsToString([]saft.Elem{elem}))
+	}
+
+	// Absent elements are encoded too.
+	data, _ = saft.Elem{}.MarshalBinary()
+	checkError(t, "UnmarshalBinary()", got.UnmarshalBinary(data), "nil")
+	if !got.IsZero() {
+		t.Fatalf("UnmarshalBinary() got %s; want absent element", got.Kind())
+	}
+
+	data = saft.AppendBinary(nil, nil, saft.BinaryOptions{})
+	checkError(t, "UnmarshalBinary()", got.UnmarshalBinary(data), "binary encoding: expected 1 root element, found 0")
+}
+
+func TestParseBinary_Errors(t *testing.T) {
+	elems, _ := saft.Parse(strings.NewReader("[a b]"))
+	valid := string(saft.AppendBinary(nil, elems, saft.BinaryOptions{Positions: true, Forms: true}))
+
+	testData := []struct {
+		data string
+		want string
+	}{
+		{"", "binary encoding: offset 0: not a binary encoding"},
+		{"saft\x02\x00\x00", "binary encoding: offset 5: unsupported version 2"},
+		{"saft\x01\x04\x00", "binary encoding: offset 6: unknown flags 0x4"},
+		{"saft\x01\x00\x01x", "binary encoding: offset 7: invalid tag 0x78"},
+		{"saft\x01\x00\x05s", "binary encoding: offset 7: count 5 exceeds data size"},
+		{"saft\x01\x00\x01s\x05abc", "binary encoding: offset 9: count 5 exceeds data size"},
+		{"saft\x01\x00\x01l\x80", "binary encoding: offset 9: unexpected end of data"},
+		{"saft\x01\x02\x01s\x07\x00", "binary encoding: offset 8: invalid string form 7"},
+		{"saft\x01\x01\x01n\x01\x08\x09", "binary encoding: offset 10: invalid column unit 9"},
+		{"saft\x01\x01\x01s\x00\x00\x01\x05\x00\x00\x00", "binary encoding: offset 12: invalid source 5"},
+		{valid[:len(valid)-1], "binary encoding: offset " + strconv.Itoa(len(valid)-1) + ": unexpected end of data"},
+		{valid + "x", "binary encoding: offset " + strconv.Itoa(len(valid)) + ": trailing data"},
+		{"saft\x01\x00\x01" + strings.Repeat("l\x01", 10001) + "n", "binary encoding: offset 20007: maximum nesting depth exceeded"},
+	}
+
+	for _, td := range testData {
+		_, err := saft.ParseBinary([]byte(td.data))
+		checkError(t, "ParseBinary()", err, td.want)
+	}
+}
+
+func BenchmarkParseBinary(b *testing.B) {
+	elems, err := saft.ParseBytes(benchDocument(1 << 20))
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := saft.AppendBinary(nil, elems, saft.BinaryOptions{Positions: true, Forms: true})
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := saft.ParseBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
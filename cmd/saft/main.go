@@ -0,0 +1,79 @@
+// Command saft works with Saft documents.
+//
+// Usage:
+//
+//	saft convert [-to=bin] [-positions] [-forms] [-o output] [input]
+//
+// The convert command converts a document to another encoding. The input is
+// read from standard input if not given and the output is written to standard
+// output unless -o is given. The only supported target encoding is "bin", the
+// binary encoding decoded by saft.ParseBinary.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/johan-bolmsjo/saft"
+	"io"
+	"os"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "saft: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command, expected: convert")
+	}
+	switch args[0] {
+	case "convert":
+		return convert(args[1:])
+	}
+	return fmt.Errorf("unknown command %q, expected: convert", args[0])
+}
+
+func convert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	to := flags.String("to", "bin", "target encoding")
+	positions := flags.Bool("positions", true, "include element positions")
+	forms := flags.Bool("forms", true, "include string forms")
+	output := flags.String("o", "", "output file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *to != "bin" {
+		return fmt.Errorf("unsupported target encoding %q, expected: bin", *to)
+	}
+
+	var data []byte
+	var err error
+	name := "<stdin>"
+	switch flags.NArg() {
+	case 0:
+		data, err = io.ReadAll(os.Stdin)
+	case 1:
+		name = flags.Arg(0)
+		data, err = os.ReadFile(name)
+	default:
+		return fmt.Errorf("too many arguments")
+	}
+	if err != nil {
+		return err
+	}
+
+	elems, err := saft.ParseBytes(data)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	bin := saft.AppendBinary(nil, elems, saft.BinaryOptions{Positions: *positions, Forms: *forms})
+
+	if *output == "" {
+		_, err = os.Stdout.Write(bin)
+		return err
+	}
+	return os.WriteFile(*output, bin, 0o644)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.saft")
+	output := filepath.Join(dir, "out.bin")
+	if err := os.WriteFile(input, []byte("{a: [b c]}\nd"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"convert", "-o", output, input}); err != nil {
+		t.Fatalf("convert error = %q", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := saft.ParseBinary(data)
+	if err != nil {
+		t.Fatalf("saft.ParseBinary() error = %q", err)
+	}
+	want, _ := saft.ParseBytes([]byte("{a: [b c]}\nd"))
+	if len(got) != len(want) {
+		t.Fatalf("converted %d elements; want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !saft.Equal(got[i], want[i], saft.EqualOptions{}) {
+			t.Fatalf("converted element %d differs", i)
+		}
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.saft")
+	if err := os.WriteFile(invalid, []byte("[a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tbl = []struct {
+		args  []string
+		error string
+	}{
+		{nil, "missing command, expected: convert"},
+		{[]string{"format"}, `unknown command "format", expected: convert`},
+		{[]string{"convert", "-to=json"}, `unsupported target encoding "json", expected: bin`},
+		{[]string{"convert", "a", "b"}, "too many arguments"},
+		{[]string{"convert", invalid}, invalid + ": 1:2: unterminated list"},
+	}
+
+	for _, td := range tbl {
+		err := run(td.args)
+		if err == nil || err.Error() != td.error {
+			t.Errorf("run(%q) error = %v; want %q", td.args, err, td.error)
+		}
+	}
+}
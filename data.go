@@ -0,0 +1,122 @@
+package saft
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Parse string as hexadecimal encoded binary data. Whitespace between digits
+// is ignored, allowing long values to be split over multiple lines in raw
+// strings.
+// Returns the decoded data or an error containing the position of the
+// offending character.
+func (s *String) Hex() ([]byte, error) {
+	data := make([]byte, 0, len(s.V)/2)
+	var b byte
+	var odd bool
+	var last int
+	for i, r := range s.V {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		var nibble byte
+		switch {
+		case r >= '0' && r <= '9':
+			nibble = byte(r - '0')
+		case r >= 'a' && r <= 'f':
+			nibble = byte(r - 'a' + 10)
+		case r >= 'A' && r <= 'F':
+			nibble = byte(r - 'A' + 10)
+		default:
+			pos := s.whereAt(i)
+			return nil, fmt.Errorf("%s: invalid hex digit %q", pos, r)
+		}
+		if odd {
+			data = append(data, b<<4|nibble)
+		} else {
+			b = nibble
+		}
+		odd = !odd
+		last = i
+	}
+	if odd {
+		pos := s.whereAt(last)
+		return nil, fmt.Errorf("%s: odd number of hex digits", pos)
+	}
+	return data, nil
+}
+
+// Parse string as base64 encoded binary data. Both the standard and the URL
+// safe alphabet are accepted, padding is optional and whitespace is ignored.
+// Returns the decoded data or an error containing the position of the
+// offending character.
+func (s *String) Base64() ([]byte, error) {
+	// Remove whitespace while remembering the index of each remaining byte in
+	// the original string.
+	var sb strings.Builder
+	index := make([]int, 0, len(s.V)+1)
+	for i, r := range s.V {
+		if !unicode.IsSpace(r) {
+			sb.WriteRune(r)
+			for n := utf8.RuneLen(r); n > 0; n-- {
+				index = append(index, i)
+			}
+		}
+	}
+	index = append(index, len(s.V))
+
+	clean := strings.TrimRight(sb.String(), "=")
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(clean, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	data, err := enc.DecodeString(clean)
+	if err != nil {
+		var i int
+		if offset, ok := err.(base64.CorruptInputError); ok && int(offset) < len(index) {
+			i = index[offset]
+		}
+		pos := s.whereAt(i)
+		return nil, fmt.Errorf("%s: invalid base64 data", pos)
+	}
+	return data, nil
+}
+
+// Parse string as one or more PEM encoded blocks, typically written as a
+// multi-line raw string. Whitespace around blocks is ignored.
+// Returns the decoded blocks or an error containing the position of the
+// offending block or data.
+func (s *String) PEM() ([]*pem.Block, error) {
+	var blocks []*pem.Block
+	rest := []byte(s.V)
+	for {
+		offset := len(s.V) - len(rest)
+		i := bytes.IndexFunc(rest, func(r rune) bool { return !unicode.IsSpace(r) })
+		if i < 0 {
+			break
+		}
+		pos := s.whereAt(offset + i)
+		if !bytes.HasPrefix(rest[i:], []byte("-----BEGIN")) {
+			return nil, fmt.Errorf("%s: unexpected data outside of PEM block", pos)
+		}
+		// pem.Decode skips malformed blocks and returns the next valid block, so
+		// check that no other block begins within the decoded data.
+		block, next := pem.Decode(rest[i:])
+		consumed := len(rest[i:]) - len(next)
+		if block == nil || bytes.Contains(rest[i+1:i+consumed], []byte("-----BEGIN")) {
+			return nil, fmt.Errorf("%s: invalid PEM block", pos)
+		}
+		blocks = append(blocks, block)
+		rest = next
+	}
+
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%s: no PEM data found", s.where())
+	}
+	return blocks, nil
+}
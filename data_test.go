@@ -0,0 +1,76 @@
+package saft_test
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestString_Hex(t *testing.T) {
+	s, _ := getTestElem(t, Q+`00ff 10
+	Ab`+Q).ExpectString()
+	v, err := s.Hex()
+	if !bytes.Equal(v, []byte{0x00, 0xff, 0x10, 0xab}) || err != nil {
+		t.Fatalf("s.Hex() = (%x, %q); want (00ff10ab, nil)", v, errorString(err))
+	}
+
+	var tbl = []struct{ input, error string }{
+		{Q + "00\n  fx" + Q, "2:3: invalid hex digit 'x'"},
+		{`abc`, "1:2: odd number of hex digits"},
+	}
+	for _, td := range tbl {
+		s, _ := getTestElem(t, td.input).ExpectString()
+		_, err := s.Hex()
+		checkError(t, td.input+".Hex()", err, td.error)
+	}
+}
+
+func TestString_Base64(t *testing.T) {
+	var tbl = []struct {
+		input string
+		want  []byte
+	}{
+		{`"+/+/"`, []byte{0xfb, 0xff, 0xbf}},
+		{`-_-_`, []byte{0xfb, 0xff, 0xbf}},
+		{`aGk=`, []byte("hi")},
+		{`aGk`, []byte("hi")},
+		{Q + "aGVs\n  bG8=" + Q, []byte("hello")},
+	}
+	for _, td := range tbl {
+		s, _ := getTestElem(t, td.input).ExpectString()
+		v, err := s.Base64()
+		if !bytes.Equal(v, td.want) || err != nil {
+			t.Fatalf("%s.Base64() = (%q, %q); want (%q, nil)", td.input, v, errorString(err), td.want)
+		}
+	}
+
+	s, _ := getTestElem(t, Q+"aGVs\n  b!G8="+Q).ExpectString()
+	_, err := s.Base64()
+	checkError(t, "s.Base64()", err, "2:3: invalid base64 data")
+}
+
+const testPEM = `-----BEGIN TEST-----
+aGVsbG8=
+-----END TEST-----
+`
+
+func TestString_PEM(t *testing.T) {
+	s, _ := getTestElem(t, Q+"\n"+testPEM+testPEM+Q).ExpectString()
+	blocks, err := s.PEM()
+	checkError(t, "s.PEM()", err, "nil")
+	if len(blocks) != 2 || blocks[1].Type != "TEST" || string(blocks[1].Bytes) != "hello" {
+		t.Fatalf("s.PEM() = %v", blocks)
+	}
+
+	var tbl = []struct{ input, error string }{
+		{Q + testPEM + "junk" + Q, "4:0: unexpected data outside of PEM block"},
+		{Q + testPEM + "-----BEGIN X-----\n!!\n-----END X-----\n" + Q, "4:0: invalid PEM block"},
+		{Q + "-----BEGIN BAD-----\n!!\n-----END BAD-----\n" + testPEM + Q, "1:1: invalid PEM block"},
+		{Q + testPEM + "-----BEGIN TEST-----\n!!\n-----END TEST-----\n" + testPEM + Q, "4:0: invalid PEM block"},
+		{Q + " " + Q, "1:0: no PEM data found"},
+	}
+	for _, td := range tbl {
+		s, _ := getTestElem(t, td.input).ExpectString()
+		_, err := s.PEM()
+		checkError(t, td.input+".PEM()", err, td.error)
+	}
+}
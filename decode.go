@@ -0,0 +1,278 @@
+package saft
+
+import (
+	"encoding"
+	"fmt"
+	"github.com/johan-bolmsjo/errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Decode decodes the element e into the value pointed to by v.
+//
+// Strings are decoded into strings, booleans, numbers, time.Duration (see
+// String.Duration), time.Time (RFC 3339), types registered by RegisterEnum and
+// types implementing encoding.TextUnmarshaler. Lists are decoded into slices.
+// Association lists are decoded into maps with string keys and into structs.
+// Fields of type Elem are set to the element as is. Pointers are allocated as
+// needed.
+//
+// Struct fields are mapped to keys as described by Overlay.AddStruct. Keys
+// not mapped to a field and duplicate keys are errors. A field missing in the
+// association list is set to the default value given by its tag, such as
+// `saft:"port,default=8080"`, or is left as is. Nested structs missing in the
+// association list get the default values of their fields. The default value
+// is decoded like a value of the document. It's a string for types decoded from
+// strings and otherwise a document of one element, such as `default=[a b]`.
+// The default option must be the last option of the tag since it may contain
+// commas.
+//
+// Returns an error containing positional information.
+func Decode(e Elem, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("saft: Decode of non-pointer or nil pointer %T", v)
+	}
+	return decodeValue(e, rv.Elem())
+}
+
+var (
+	elemType     = reflect.TypeOf(Elem{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// decodedFromString returns true if values of type t are decoded from strings.
+// The saftvet analyzer decides the same for static types, see
+// decodedFromString in package saftvet.
+func decodedFromString(t reflect.Type) bool {
+	if t == durationType || t == timeType || lookupEnum(t) != nil ||
+		reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func decodeValue(e Elem, rv reflect.Value) error {
+	t := rv.Type()
+	if t == elemType {
+		rv.Set(reflect.ValueOf(e))
+		return nil
+	}
+	if t.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		return decodeValue(e, rv.Elem())
+	}
+
+	if decodedFromString(t) {
+		s, err := e.ExpectString()
+		if err != nil {
+			return err
+		}
+		return decodeString(s, rv)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		assoc, err := e.ExpectAssoc()
+		if err != nil {
+			return err
+		}
+		return decodeStruct(assoc, rv)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+		assoc, err := e.ExpectAssoc()
+		if err != nil {
+			return err
+		}
+		return decodeMap(assoc, rv)
+	case reflect.Slice:
+		list, err := e.ExpectList()
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(t, len(list.L), len(list.L))
+		for i := range list.L {
+			if err := decodeValue(list.L[i], slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	}
+
+	return fmt.Errorf("%s: cannot decode %s into value of type %s", e.where(), e.Kind(), t)
+}
+
+func decodeString(s *String, rv reflect.Value) error {
+	t := rv.Type()
+	if enum := lookupEnum(t); enum != nil {
+		v, err := enum.parseValue(s)
+		if err != nil {
+			return err
+		}
+		rv.Set(v)
+		return nil
+	}
+
+	switch t {
+	case durationType:
+		d, err := s.Duration()
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	case timeType:
+		tm, err := s.Time("")
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(tm))
+		return nil
+	}
+
+	if u, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(s.V)); err != nil {
+			return errors.Wrap(err, s.where())
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		rv.SetString(s.V)
+	case reflect.Bool:
+		v, err := s.Bool()
+		if err != nil {
+			return err
+		}
+		rv.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := t.Bits()
+		v, err := s.IntInRange(-1<<(bits-1), 1<<(bits-1)-1)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v, err := s.UintInRange(0, ^uint64(0)>>(64-t.Bits()))
+		if err != nil {
+			return err
+		}
+		rv.SetUint(v)
+	case reflect.Float32:
+		v, err := s.Float32()
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(float64(v))
+	case reflect.Float64:
+		v, err := s.Float64()
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(v)
+	}
+	return nil
+}
+
+func decodeStruct(assoc *Assoc, rv reflect.Value) error {
+	fields := structFields(rv.Type())
+
+	if i := assoc.L.duplicate(); i >= 0 {
+		key := &assoc.L[i].K
+		return fmt.Errorf("%s: duplicate key %q", key.where(), key.V)
+	}
+	for i := range assoc.L {
+		key := &assoc.L[i].K
+		known := false
+		for j := range fields {
+			if fields[j].key == key.V {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%s: unknown key %q", key.where(), key.V)
+		}
+	}
+
+	for j := range fields {
+		f := &fields[j]
+		fv := rv.Field(f.index)
+		if i := assoc.L.index(f.key); i >= 0 {
+			if err := decodeValue(assoc.L[i].V, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if def, ok := f.defaultValue(); ok {
+			e, err := f.defaultElem(def)
+			if err != nil {
+				return err
+			}
+			if err := decodeValue(e, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.typ.Kind() == reflect.Struct && f.typ != elemType && !decodedFromString(f.typ) {
+			if err := decodeStruct(&Assoc{}, fv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// defaultElem returns the default value of the field as an element.
+func (f *structField) defaultElem(def string) (Elem, error) {
+	origin := "default of field " + f.name
+	t := f.typ
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if decodedFromString(t) {
+		return Elem{NewStringOrigin(def, origin)}, nil
+	}
+	elems, err := Parse(strings.NewReader(def))
+	if err == nil && len(elems) != 1 {
+		err = fmt.Errorf("expected one element, found %d", len(elems))
+	}
+	if err != nil {
+		return Elem{}, fmt.Errorf("%s: %s", origin, err)
+	}
+	return elems[0], nil
+}
+
+func decodeMap(assoc *Assoc, rv reflect.Value) error {
+	t := rv.Type()
+	if i := assoc.L.duplicate(); i >= 0 {
+		key := &assoc.L[i].K
+		return fmt.Errorf("%s: duplicate key %q", key.where(), key.V)
+	}
+	m := reflect.MakeMapWithSize(t, len(assoc.L))
+	for i := range assoc.L {
+		key := &assoc.L[i].K
+		v := reflect.New(t.Elem()).Elem()
+		if err := decodeValue(assoc.L[i].V, v); err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(key.V).Convert(t.Key()), v)
+	}
+	rv.Set(m)
+	return nil
+}
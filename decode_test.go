@@ -0,0 +1,123 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type decodeTestLevel int
+
+const (
+	decodeTestLevelInfo decodeTestLevel = iota
+	decodeTestLevelDebug
+)
+
+func init() {
+	saft.RegisterEnum((&saft.Enum[decodeTestLevel]{}).
+		Add("info", decodeTestLevelInfo).
+		Add("debug", decodeTestLevelDebug))
+}
+
+type decodeTestConfig struct {
+	Server struct {
+		Host    string        `saft:"host,default=localhost"`
+		Port    uint16        `saft:"port,default=8080" saftdoc:"Port to listen on"`
+		Timeout time.Duration `saft:"read-timeout,default=5s"`
+		Addr    netip.Addr
+	} `saft:"server"`
+	Level   decodeTestLevel `saft:"level,default=debug"`
+	Tags    []string        `saft:"tags,default=[a b]"`
+	Labels  map[string]string
+	Ratio   *float64
+	Start   time.Time
+	Extra   saft.Elem
+	Ignored string `saft:"-"`
+}
+
+func TestDecode(t *testing.T) {
+	var cfg decodeTestConfig
+	e := getTestElem(t, `{
+		server: {port: 9000 addr: 10.0.0.1}
+		labels: {x: y}
+		ratio: 0.5
+		start: "2024-01-02T03:04:05Z"
+		extra: [1 2]
+	}`)
+	if err := saft.Decode(e, &cfg); err != nil {
+		t.Fatalf("saft.Decode() error = %q", err)
+	}
+
+	if got, want := cfg.Server.Host, "localhost"; got != want {
+		t.Fatalf("got host %q; want %q", got, want)
+	}
+	if got, want := cfg.Server.Port, uint16(9000); got != want {
+		t.Fatalf("got port %d; want %d", got, want)
+	}
+	if got, want := cfg.Server.Timeout, 5*time.Second; got != want {
+		t.Fatalf("got timeout %v; want %v", got, want)
+	}
+	if got, want := cfg.Server.Addr, netip.MustParseAddr("10.0.0.1"); got != want {
+		t.Fatalf("got addr %v; want %v", got, want)
+	}
+	if got, want := cfg.Level, decodeTestLevelDebug; got != want {
+		t.Fatalf("got level %v; want %v", got, want)
+	}
+	if got, want := cfg.Tags, []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got tags %q; want %q", got, want)
+	}
+	if got, want := cfg.Labels, map[string]string{"x": "y"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got labels %q; want %q", got, want)
+	}
+	if cfg.Ratio == nil || *cfg.Ratio != 0.5 {
+		t.Fatalf("got ratio %v; want 0.5", cfg.Ratio)
+	}
+	if got, want := cfg.Start, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("got start %v; want %v", got, want)
+	}
+	if got, want := elemsToString([]saft.Elem{cfg.Extra}), `["1" "2" ] `; got != want {
+		t.Fatalf("got extra %s; want %s", got, want)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	testData := []struct {
+		doc  string
+		want string
+	}{
+		{`{server: {port: 70000}}`, "1:16: value 70000 out of range [0, 65535]"},
+		{`{server: {prot: 1}}`, `1:10: unknown key "prot"`},
+		{`{level: info level: debug}`, `1:13: duplicate key "level"`},
+		{`{labels: {a:1 b:2 c:3 d:4 e:5 f:6 g:7 h:8 i:9 b:10}}`, `1:46: duplicate key "b"`},
+		{`{tags: a}`, "1:7: expected list, found string"},
+		{`{server: {addr: x}}`, `1:16: ParseAddr("x"): unable to parse IP`},
+		{`[]`, "1:0: expected association list, found list"},
+	}
+	for _, td := range testData {
+		var cfg decodeTestConfig
+		err := saft.Decode(getTestElem(t, td.doc), &cfg)
+		checkError(t, "saft.Decode("+td.doc+")", err, td.want)
+	}
+}
+
+func TestDecode_InvalidDefault(t *testing.T) {
+	var cfg struct {
+		Port int      `saft:"port,default=http"`
+		List []string `saft:"list,default=[a"`
+	}
+	err := saft.Decode(getTestElem(t, `{list: []}`), &cfg)
+	checkError(t, "saft.Decode()", err, `default of field Port: strconv.ParseInt: parsing "http": invalid syntax`)
+
+	err = saft.Decode(getTestElem(t, `{port: 1}`), &cfg)
+	if err == nil {
+		t.Fatalf("saft.Decode() error = nil; want error")
+	}
+}
+
+func TestDecode_NonPointer(t *testing.T) {
+	var cfg decodeTestConfig
+	err := saft.Decode(getTestElem(t, `{}`), cfg)
+	checkError(t, "saft.Decode()", err, "saft: Decode of non-pointer or nil pointer saft_test.decodeTestConfig")
+}
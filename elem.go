@@ -2,41 +2,100 @@ package saft
 
 import (
 	"fmt"
+	"github.com/johan-bolmsjo/errors"
+	"strconv"
 )
 
 // Elem is any of the Saft data types String, List or Assoc.
+//
+// An Elem may also be absent, either because it's the zero Elem or because it's
+// the result of a lookup that found nothing. Absent elements report KindNone
+// and cause the Expect functions to return a "missing value" error.
 type Elem struct {
 	any elem
 }
 
+// Value is any of the element types *String, *List or *Assoc.
+// Other types can not implement the interface.
+type Value interface {
+	elem
+}
+
+// ElemOf returns an element holding v. A nil v, including a nil pointer of any
+// of the element types, results in the zero Elem.
+func ElemOf(v Value) Elem {
+	switch x := v.(type) {
+	case *String:
+		if x == nil {
+			return Elem{}
+		}
+	case *List:
+		if x == nil {
+			return Elem{}
+		}
+	case *Assoc:
+		if x == nil {
+			return Elem{}
+		}
+	case nil:
+		return Elem{}
+	}
+	return Elem{v}
+}
+
 type elem interface {
 	Pos() LexPos
-	elemType() elemType
+	kind() Kind
 }
 
-type elemType int
+// Kind is the type of an element.
+type Kind int
 
 const (
-	elemTypeString elemType = iota
-	elemTypeList
-	elemTypeAssoc
+	KindNone Kind = iota // Absent element
+	KindString
+	KindList
+	KindAssoc
 )
 
-func (et elemType) String() string {
-	switch et {
-	case elemTypeString:
+// String implements the fmt.Stringer interface.
+func (kind Kind) String() string {
+	switch kind {
+	case KindNone:
+		return "nothing"
+	case KindString:
 		return "string"
-	case elemTypeList:
+	case KindList:
 		return "list"
-	case elemTypeAssoc:
+	case KindAssoc:
 		return "association list"
 	}
 	return "?"
 }
 
+// Kind returns the type of the element.
+// Useful in switch statements as an alternative to a chain of type assertions.
+func (e Elem) Kind() Kind {
+	if e.any == nil {
+		return KindNone
+	}
+	return e.any.kind()
+}
+
+// IsZero returns true if the element is absent.
+func (e Elem) IsZero() bool {
+	return e.Kind() == KindNone
+}
+
 // Pos returns the lexed position of the element.
 // Useful for error reporting if the element does not match an expected type.
+// The position of an absent element returned by a lookup is the position of
+// the element where the lookup failed. The zero Elem has no position and
+// returns the zero LexPos, see LexPos.IsValid.
 func (e Elem) Pos() LexPos {
+	if e.any == nil {
+		return LexPos{}
+	}
 	return e.any.Pos()
 }
 
@@ -61,16 +120,36 @@ func (e Elem) IsAssoc() (t *Assoc, ok bool) {
 	return
 }
 
-func expectError(e *Elem, expected elemType) error {
+func expectError(e *Elem, expected Kind) error {
+	switch x := e.any.(type) {
+	case nil:
+		return errors.New("missing value")
+	case *missing:
+		return x.err
+	}
+	return fmt.Errorf("%s: expected %s, found %s", e.where(), expected, e.Kind())
+}
+
+// where returns the position of e for error messages, which is its origin if
+// it has one.
+func (e Elem) where() string {
+	switch x := e.any.(type) {
+	case *String:
+		return x.where()
+	case *List:
+		return x.where()
+	case *Assoc:
+		return x.where()
+	}
 	pos := e.Pos()
-	return fmt.Errorf("%s: expected %s, found %s", &pos, expected, e.any.elemType())
+	return pos.String()
 }
 
 // ExpectString asserts and expects that e is a String.
 // Returns a string or an error containing positional information.
 func (e Elem) ExpectString() (t *String, err error) {
 	if t, _ = e.any.(*String); t == nil {
-		err = expectError(&e, elemTypeString)
+		err = expectError(&e, KindString)
 	}
 	return
 }
@@ -79,7 +158,7 @@ func (e Elem) ExpectString() (t *String, err error) {
 // Returns a List or an error containing positional information.
 func (e Elem) ExpectList() (t *List, err error) {
 	if t, _ = e.any.(*List); t == nil {
-		err = expectError(&e, elemTypeList)
+		err = expectError(&e, KindList)
 	}
 	return
 }
@@ -88,7 +167,109 @@ func (e Elem) ExpectList() (t *List, err error) {
 // Returns an Assoc or an error containing positional information.
 func (e Elem) ExpectAssoc() (t *Assoc, err error) {
 	if t, _ = e.any.(*Assoc); t == nil {
-		err = expectError(&e, elemTypeAssoc)
+		err = expectError(&e, KindAssoc)
 	}
 	return
 }
+
+// Copy returns a deep copy of e.
+// Modifying the copy does not affect the original and vice versa.
+func (e Elem) Copy() Elem {
+	switch x := e.any.(type) {
+	case *String:
+		s := *x
+		return Elem{&s}
+	case *List:
+		list := &List{pos: x.pos, src: x.src, L: make([]Elem, len(x.L))}
+		for i := range x.L {
+			list.L[i] = x.L[i].Copy()
+		}
+		return Elem{list}
+	case *Assoc:
+		assoc := &Assoc{pos: x.pos, src: x.src, L: make(Pairs, len(x.L))}
+		for i := range x.L {
+			assoc.L[i] = Pair{K: x.L[i].K, V: x.L[i].V.Copy()}
+		}
+		return Elem{assoc}
+	}
+	return e
+}
+
+// Get returns the value of the first pair with the specified key if e is an
+// Assoc. Returns an absent element otherwise. Absence propagates through
+// chained lookups so that only the final element needs to be checked:
+//
+//	port, err := e.Get("servers").Get("web").Get("port").ExpectString()
+//
+// The error then refers to the first lookup that failed. A pair holding the
+// zero Elem is treated as missing, with the error referring to the enclosing
+// Assoc.
+func (e Elem) Get(key string) Elem {
+	switch x := e.any.(type) {
+	case *missing:
+		return e
+	case *Assoc:
+		if i := x.L.index(key); i >= 0 && x.L[i].V.any != nil {
+			return x.L[i].V
+		}
+		return missingElem(fmt.Errorf("%s: missing value for key %q", x.where(), key), x.pos)
+	}
+	return e.notFound(KindAssoc)
+}
+
+// Index returns the element at index i if e is a List.
+// Returns an absent element otherwise. See Get regarding chained lookups.
+func (e Elem) Index(i int) Elem {
+	switch x := e.any.(type) {
+	case *missing:
+		return e
+	case *List:
+		if i >= 0 && i < len(x.L) && x.L[i].any != nil {
+			return x.L[i]
+		}
+		return missingElem(fmt.Errorf("%s: missing list element %d", x.where(), i), x.pos)
+	}
+	return e.notFound(KindList)
+}
+
+// Lookup returns the element addressed by path using Get for association lists
+// and Index for lists. Returns an absent element if there is no such element.
+func (e Elem) Lookup(path Path) Elem {
+	for _, seg := range path {
+		if e.Kind() == KindList {
+			i, err := strconv.Atoi(seg)
+			if err != nil {
+				return missingElem(fmt.Errorf("%s: invalid list index %q", e.where(), seg), e.Pos())
+			}
+			e = e.Index(i)
+		} else {
+			e = e.Get(seg)
+		}
+	}
+	return e
+}
+
+// notFound returns an absent element for a lookup in e which is not of the
+// expected kind.
+func (e Elem) notFound(expected Kind) Elem {
+	return missingElem(expectError(&e, expected), e.Pos())
+}
+
+// missing is an absent element returned by failed lookups.
+// It remembers why the lookup failed.
+type missing struct {
+	pos LexPos
+	err error
+}
+
+func missingElem(err error, pos LexPos) Elem {
+	return Elem{&missing{pos: pos, err: err}}
+}
+
+func (m *missing) Pos() LexPos {
+	return m.pos
+}
+
+func (m *missing) kind() Kind {
+	return KindNone
+}
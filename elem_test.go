@@ -3,6 +3,7 @@ package saft_test
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"github.com/johan-bolmsjo/saft"
 	"testing"
 )
@@ -84,3 +85,89 @@ func TestElem_Assoc(t *testing.T) {
 		t.Fatalf("stringElem.IsAssoc() = true")
 	}
 }
+
+func TestElem_Constructors(t *testing.T) {
+	if !saft.ElemOf(nil).IsZero() {
+		t.Fatalf("saft.ElemOf(nil) is not absent")
+	}
+	if !saft.ElemOf((*saft.String)(nil)).IsZero() {
+		t.Fatalf("saft.ElemOf((*saft.String)(nil)) is not absent")
+	}
+	if s, ok := saft.ElemOf(saft.NewString("a")).IsString(); !ok || s.V != "a" {
+		t.Fatalf("saft.ElemOf(saft.NewString(\"a\")).IsString() = (%v, %v)", s, ok)
+	}
+	if _, ok := saft.ElemOf(saft.NewList()).IsList(); !ok {
+		t.Fatalf("saft.ElemOf(saft.NewList()).IsList() = false")
+	}
+	if _, ok := saft.ElemOf(saft.NewAssoc()).IsAssoc(); !ok {
+		t.Fatalf("saft.ElemOf(saft.NewAssoc()).IsAssoc() = false")
+	}
+}
+
+func TestElem_Copy(t *testing.T) {
+	orig := getTestElem(t, `{a:[b c] d:e}`)
+	dup := orig.Copy()
+
+	assoc, _ := dup.IsAssoc()
+	list, _ := assoc.L[0].V.IsList()
+	list.Append(saft.ElemOf(saft.NewString("x")))
+	assoc.L.Set("d", saft.ElemOf(saft.NewString("y")))
+
+	checkElems(t, []saft.Elem{orig}, `{"a":["b" "c" ] "d":"e"  }`)
+	checkElems(t, []saft.Elem{dup}, `{"a":["b" "c" "x" ] "d":"y"  }`)
+}
+
+func TestElem_Zero(t *testing.T) {
+	var e saft.Elem
+	if !e.IsZero() || e.Kind() != saft.KindNone {
+		t.Fatalf("saft.Elem{} is not absent")
+	}
+	if pos := e.Pos(); pos != (saft.LexPos{}) || pos.IsValid() {
+		t.Fatalf("saft.Elem{}.Pos() = %s; want the invalid 0:0", &pos)
+	}
+
+	_, err := e.ExpectString()
+	checkError(t, "saft.Elem{}.ExpectString()", err, "missing value")
+	_, err = e.ExpectList()
+	checkError(t, "saft.Elem{}.ExpectList()", err, "missing value")
+	_, err = e.ExpectAssoc()
+	checkError(t, "saft.Elem{}.ExpectAssoc()", err, "missing value")
+}
+
+func TestElem_Lookup(t *testing.T) {
+	e := getTestElem(t, `{servers: {web: {port:80}} rules:[a b]}`)
+
+	// Zero elements held by an assoc or list are missing values positioned
+	// at their parent.
+	held := getTestElem(t, ` {a:x b:[y]}`)
+	heldAssoc, _ := held.IsAssoc()
+	heldAssoc.L.Set("a", saft.Elem{})
+	heldList, _ := held.Get("b").IsList()
+	heldList.Set(0, saft.Elem{})
+
+	var tbl = []struct {
+		e            saft.Elem
+		value, error string
+	}{
+		{e.Get("servers").Get("web").Get("port"), "80", "nil"},
+		{e.Get("rules").Index(1), "b", "nil"},
+		{e.Lookup(saft.ParsePath("rules.0")), "a", "nil"},
+		{e.Get("servers").Get("db").Get("port"), "", `1:10: missing value for key "db"`},
+		{e.Get("rules").Index(2).Get("x"), "", "1:33: missing list element 2"},
+		{e.Get("rules").Get("x"), "", "1:33: expected association list, found list"},
+		{e.Lookup(saft.ParsePath("rules.x")), "", `1:33: invalid list index "x"`},
+		{held.Get("a"), "", `1:1: missing value for key "a"`},
+		{held.Get("b").Index(0), "", "1:8: missing list element 0"},
+	}
+
+	for i, td := range tbl {
+		s, err := td.e.ExpectString()
+		checkError(t, fmt.Sprintf("lookup %d", i), err, td.error)
+		if err == nil && s.V != td.value {
+			t.Fatalf("lookup %d = %q; want %q", i, s.V, td.value)
+		}
+		if err != nil && !td.e.IsZero() {
+			t.Fatalf("lookup %d is not absent", i)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package saft
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// OneOf expects the string to be one of choices.
+// Returns the string value or an error containing positional information
+// listing the allowed values.
+func (s *String) OneOf(choices ...string) (string, error) {
+	for _, choice := range choices {
+		if s.V == choice {
+			return choice, nil
+		}
+	}
+	return "", oneOfError(s, choices)
+}
+
+// OneOfFold is like OneOf but compares strings under Unicode case folding.
+// The matching choice is returned rather than the string value.
+func (s *String) OneOfFold(choices ...string) (string, error) {
+	for _, choice := range choices {
+		if strings.EqualFold(s.V, choice) {
+			return choice, nil
+		}
+	}
+	return "", oneOfError(s, choices)
+}
+
+func oneOfError(s *String, choices []string) error {
+	return fmt.Errorf("%s: invalid value %q, expected one of: %s", s.where(), s.V, strings.Join(choices, ", "))
+}
+
+// Enum maps symbols to Go values, typically constants of an enumeration type.
+// The zero value is an empty enumeration ready for use.
+//
+//	var modes = (&saft.Enum[Mode]{}).
+//		Add("strict", ModeStrict).
+//		Add("lenient", ModeLenient).
+//		Add("off", ModeOff)
+type Enum[T comparable] struct {
+	FoldCase bool // Compare symbols under Unicode case folding
+	symbols  []string
+	values   []T
+}
+
+// Add a symbol and its value. Returns the enumeration to allow chaining.
+func (e *Enum[T]) Add(symbol string, v T) *Enum[T] {
+	e.symbols = append(e.symbols, symbol)
+	e.values = append(e.values, v)
+	return e
+}
+
+// Symbols returns the symbols of the enumeration in the order they were added.
+func (e *Enum[T]) Symbols() []string {
+	return append([]string(nil), e.symbols...)
+}
+
+// Symbol returns the first symbol of value v.
+// Returns false if v has no symbol.
+func (e *Enum[T]) Symbol(v T) (string, bool) {
+	for i := range e.values {
+		if e.values[i] == v {
+			return e.symbols[i], true
+		}
+	}
+	return "", false
+}
+
+// Parse the string as one of the symbols of the enumeration.
+// Returns the value of the symbol or an error containing positional
+// information listing the allowed symbols.
+func (e *Enum[T]) Parse(s *String) (v T, err error) {
+	var symbol string
+	if e.FoldCase {
+		symbol, err = s.OneOfFold(e.symbols...)
+	} else {
+		symbol, err = s.OneOf(e.symbols...)
+	}
+	if err != nil {
+		return v, err
+	}
+	for i := range e.symbols {
+		if e.symbols[i] == symbol {
+			v = e.values[i]
+			break
+		}
+	}
+	return v, nil
+}
+
+// parseValue implements enumParser.
+func (e *Enum[T]) parseValue(s *String) (reflect.Value, error) {
+	v, err := e.Parse(s)
+	return reflect.ValueOf(&v).Elem(), err
+}
+
+// symbolValue implements enumParser.
+func (e *Enum[T]) symbolValue(v reflect.Value) (string, bool) {
+	return e.Symbol(v.Interface().(T))
+}
+
+// enumParser is the type erased interface of registered enumerations.
+type enumParser interface {
+	parseValue(s *String) (reflect.Value, error)
+	symbolValue(v reflect.Value) (string, bool)
+}
+
+var enumRegistry struct {
+	sync.RWMutex
+	m map[reflect.Type]enumParser
+}
+
+// RegisterEnum registers e as the enumeration of type T, replacing any
+// previously registered enumeration of the type. This allows values of type T
+// to be parsed by ParseEnum and Decode without access to e.
+func RegisterEnum[T comparable](e *Enum[T]) {
+	enumRegistry.Lock()
+	defer enumRegistry.Unlock()
+	if enumRegistry.m == nil {
+		enumRegistry.m = make(map[reflect.Type]enumParser)
+	}
+	enumRegistry.m[reflect.TypeOf((*T)(nil)).Elem()] = e
+}
+
+func lookupEnum(t reflect.Type) enumParser {
+	enumRegistry.RLock()
+	defer enumRegistry.RUnlock()
+	return enumRegistry.m[t]
+}
+
+// ParseEnum parses the string using the enumeration registered for type T.
+// Returns the parsed value or an error containing positional information.
+func ParseEnum[T comparable](s *String) (v T, err error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	e := lookupEnum(t)
+	if e == nil {
+		return v, fmt.Errorf("%s: no enumeration registered for type %s", s.where(), t)
+	}
+	rv, err := e.parseValue(s)
+	if err != nil {
+		return v, err
+	}
+	return rv.Interface().(T), nil
+}
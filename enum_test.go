@@ -0,0 +1,66 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"testing"
+)
+
+type testMode int
+
+const (
+	testModeStrict testMode = iota + 1
+	testModeLenient
+	testModeOff
+)
+
+func TestString_OneOf(t *testing.T) {
+	v, err := (&saft.String{V: "lenient"}).OneOf("strict", "lenient", "off")
+	if v != "lenient" || err != nil {
+		t.Fatalf(`"lenient".OneOf() = (%q, %q); want ("lenient", nil)`, v, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "Off"}).OneOf("strict", "lenient", "off")
+	checkError(t, `"Off".OneOf()`, err, `0:0: invalid value "Off", expected one of: strict, lenient, off`)
+
+	v, err = (&saft.String{V: "Off"}).OneOfFold("strict", "lenient", "off")
+	if v != "off" || err != nil {
+		t.Fatalf(`"Off".OneOfFold() = (%q, %q); want ("off", nil)`, v, errorString(err))
+	}
+}
+
+func TestEnum(t *testing.T) {
+	modes := (&saft.Enum[testMode]{}).
+		Add("strict", testModeStrict).
+		Add("lenient", testModeLenient).
+		Add("off", testModeOff)
+
+	v, err := modes.Parse(&saft.String{V: "off"})
+	if v != testModeOff || err != nil {
+		t.Fatalf(`modes.Parse("off") = (%v, %q); want (%v, nil)`, v, errorString(err), testModeOff)
+	}
+
+	_, err = modes.Parse(&saft.String{V: "OFF"})
+	checkError(t, `modes.Parse("OFF")`, err, `0:0: invalid value "OFF", expected one of: strict, lenient, off`)
+
+	modes.FoldCase = true
+	v, err = modes.Parse(&saft.String{V: "OFF"})
+	if v != testModeOff || err != nil {
+		t.Fatalf(`modes.Parse("OFF") = (%v, %q); want (%v, nil)`, v, errorString(err), testModeOff)
+	}
+
+	if sym, ok := modes.Symbol(testModeLenient); sym != "lenient" || !ok {
+		t.Fatalf("modes.Symbol(%v) = (%q, %v); want (\"lenient\", true)", testModeLenient, sym, ok)
+	}
+}
+
+func TestParseEnum(t *testing.T) {
+	type unregistered int
+	_, err := saft.ParseEnum[unregistered](&saft.String{V: "x"})
+	checkError(t, "saft.ParseEnum[unregistered]()", err, "0:0: no enumeration registered for type saft_test.unregistered")
+
+	saft.RegisterEnum((&saft.Enum[testMode]{}).Add("strict", testModeStrict))
+	v, err := saft.ParseEnum[testMode](&saft.String{V: "strict"})
+	if v != testModeStrict || err != nil {
+		t.Fatalf(`saft.ParseEnum[testMode]("strict") = (%v, %q); want (%v, nil)`, v, errorString(err), testModeStrict)
+	}
+}
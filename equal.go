@@ -0,0 +1,145 @@
+package saft
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sort"
+)
+
+// EqualOptions control what is considered by Equal.
+// The zero value compares everything.
+type EqualOptions struct {
+	IgnorePos       bool // Ignore lexed positions and origins of elements
+	IgnoreForm      bool // Ignore the syntax form of strings
+	IgnorePairOrder bool // Ignore the order of pairs with different keys in association lists
+}
+
+// Equal reports whether the element trees a and b are deeply equal.
+//
+// Pairs with identical keys must appear in the same relative order even if
+// IgnorePairOrder is set, since that order is significant to lookups.
+func Equal(a, b Elem, opts EqualOptions) bool {
+	return opts.equal(a, b)
+}
+
+func (opts *EqualOptions) equal(a, b Elem) bool {
+	switch x := a.any.(type) {
+	case *String:
+		y, ok := b.IsString()
+		return ok && opts.equalString(x, y)
+	case *List:
+		y, ok := b.IsList()
+		if !ok || len(x.L) != len(y.L) || !opts.equalPos(x.pos, y.pos) || !opts.equalOrigin(x.src, y.src) {
+			return false
+		}
+		for i := range x.L {
+			if !opts.equal(x.L[i], y.L[i]) {
+				return false
+			}
+		}
+		return true
+	case *Assoc:
+		y, ok := b.IsAssoc()
+		if !ok || len(x.L) != len(y.L) || !opts.equalPos(x.pos, y.pos) || !opts.equalOrigin(x.src, y.src) {
+			return false
+		}
+		xl, yl := x.L, y.L
+		if opts.IgnorePairOrder {
+			xl, yl = sortedPairs(xl), sortedPairs(yl)
+		}
+		for i := range xl {
+			if !opts.equalString(&xl[i].K, &yl[i].K) || !opts.equal(xl[i].V, yl[i].V) {
+				return false
+			}
+		}
+		return true
+	}
+	return b.IsZero()
+}
+
+func (opts *EqualOptions) equalString(x, y *String) bool {
+	return x.V == y.V && opts.equalPos(x.pos, y.pos) && opts.equalOrigin(x.src, y.src) &&
+		(opts.IgnoreForm || x.form == y.form)
+}
+
+func (opts *EqualOptions) equalPos(x, y LexPos) bool {
+	return opts.IgnorePos || x == y
+}
+
+func (opts *EqualOptions) equalOrigin(x, y *source) bool {
+	return opts.IgnorePos || x.originOf() == y.originOf()
+}
+
+// sortedPairs returns a copy of lst stably sorted by key.
+func sortedPairs(lst Pairs) Pairs {
+	sorted := append(Pairs(nil), lst...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].K.V < sorted[j].K.V })
+	return sorted
+}
+
+// Hash returns the SHA-256 hash of a canonical serialisation of the element
+// trees. Positions and the syntax form of strings are not part of the
+// serialisation, so documents that only differ in formatting hash identically.
+// The order of pairs in association lists is significant.
+func Hash(elems ...Elem) (sum [sha256.Size]byte) {
+	h := sha256.New()
+	w := hashWriter{h: h}
+	w.uvarint(uint64(len(elems)))
+	for _, e := range elems {
+		w.elem(e)
+	}
+	h.Sum(sum[:0])
+	return
+}
+
+// Tags of the canonical serialisation used by Hash.
+const (
+	hashTagNone   = 'n'
+	hashTagString = 's'
+	hashTagList   = 'l'
+	hashTagAssoc  = 'a'
+)
+
+type hashWriter struct {
+	h   hash.Hash
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *hashWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.buf[:], v)
+	w.h.Write(w.buf[:n])
+}
+
+func (w *hashWriter) tag(tag byte) {
+	w.buf[0] = tag
+	w.h.Write(w.buf[:1])
+}
+
+func (w *hashWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.h.Write([]byte(s))
+}
+
+func (w *hashWriter) elem(e Elem) {
+	switch x := e.any.(type) {
+	case *String:
+		w.tag(hashTagString)
+		w.string(x.V)
+	case *List:
+		w.tag(hashTagList)
+		w.uvarint(uint64(len(x.L)))
+		for i := range x.L {
+			w.elem(x.L[i])
+		}
+	case *Assoc:
+		w.tag(hashTagAssoc)
+		w.uvarint(uint64(len(x.L)))
+		for i := range x.L {
+			w.string(x.L[i].K.V)
+			w.elem(x.L[i].V)
+		}
+	default:
+		w.tag(hashTagNone)
+	}
+}
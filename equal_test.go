@@ -0,0 +1,53 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	var tbl = []struct {
+		a, b string
+		opts saft.EqualOptions
+		want bool
+	}{
+		{`{a:[b c]}`, `{a:[b c]}`, saft.EqualOptions{}, true},
+		{`{a:[b c]}`, `{a:[b d]}`, saft.EqualOptions{IgnorePos: true}, false},
+		{`{a:[b c]}`, `{a: [b c]}`, saft.EqualOptions{}, false},
+		{`{a:[b c]}`, `{a: [b c]}`, saft.EqualOptions{IgnorePos: true}, true},
+		{`a`, `"a"`, saft.EqualOptions{}, false},
+		{`a`, `"a"`, saft.EqualOptions{IgnoreForm: true}, true},
+		{`{a:1 b:2}`, `{b:2 a:1}`, saft.EqualOptions{IgnorePos: true}, false},
+		{`{a:1 b:2}`, `{b:2 a:1}`, saft.EqualOptions{IgnorePos: true, IgnorePairOrder: true}, true},
+		{`{a:1 a:2}`, `{a:2 a:1}`, saft.EqualOptions{IgnorePos: true, IgnorePairOrder: true}, false},
+		{`[]`, `{}`, saft.EqualOptions{IgnorePos: true}, false},
+	}
+
+	for _, td := range tbl {
+		a, b := getTestElem(t, td.a), getTestElem(t, td.b)
+		if got := saft.Equal(a, b, td.opts); got != td.want {
+			t.Fatalf("saft.Equal(%s, %s, %+v) = %v; want %v", td.a, td.b, td.opts, got, td.want)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	a := getTestElem(t, `{a: [b "c"] d:`+Q+`e`+Q+`}`)
+	b := getTestElem(t, `{a:[b c]
+d:e}`)
+	c := getTestElem(t, `{d:e a:[b c]}`)
+	d := getTestElem(t, `{a:["b c"] d:e}`)
+
+	if saft.Hash(a) != saft.Hash(b) {
+		t.Fatalf("saft.Hash() differs for documents only differing in formatting")
+	}
+	if saft.Hash(a) == saft.Hash(c) {
+		t.Fatalf("saft.Hash() equal for documents with different pair order")
+	}
+	if saft.Hash(a) == saft.Hash(d) {
+		t.Fatalf("saft.Hash() equal for documents with different strings")
+	}
+	if saft.Hash(a) == saft.Hash(a, a) {
+		t.Fatalf("saft.Hash() equal for documents with different number of elements")
+	}
+}
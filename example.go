@@ -0,0 +1,316 @@
+package saft
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StructDocument returns an example document of the struct v, or the struct v points
+// to, suitable for printing a default configuration. The document is an
+// association list with a pair for each field mapped to a key as described by
+// Decode. Fields are preceded by comments from their "saftdoc" tag, such as
+// `saftdoc:"Port to listen on"`.
+//
+// The value of a field is its value in v unless zero, otherwise its default
+// value, otherwise the zero value of its type. Decoding the document yields
+// the same values as v for supported types with non-zero values or defaults.
+// Fields of type Elem that are absent and have no default are omitted.
+func StructDocument(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() && rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv = reflect.New(rv.Type().Elem())
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("saft: StructDocument of non-struct type %T", v))
+	}
+	var sb strings.Builder
+	writeExampleStruct(&sb, rv, 0, nil)
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func writeIndent(sb *strings.Builder, indent int) {
+	for i := 0; i < indent; i++ {
+		sb.WriteString("  ")
+	}
+}
+
+func writeExampleStruct(sb *strings.Builder, rv reflect.Value, indent int, seen []reflect.Type) {
+	t := rv.Type()
+	for _, s := range seen {
+		if s == t {
+			sb.WriteString("{}") // Recursive type
+			return
+		}
+	}
+	seen = append(seen, t)
+
+	fields := structFields(t)
+	present := fields[:0]
+	for _, f := range fields {
+		if _, hasDef := f.defaultValue(); hasDef || !isAbsentElem(rv.Field(f.index)) {
+			present = append(present, f)
+		}
+	}
+	fields = present
+	if len(fields) == 0 {
+		sb.WriteString("{}")
+		return
+	}
+	sb.WriteString("{\n")
+	for i := range fields {
+		f := &fields[i]
+		if f.doc != "" {
+			for _, line := range strings.Split(f.doc, "\n") {
+				writeIndent(sb, indent+1)
+				sb.WriteString(strings.TrimRight("// "+line, " "))
+				sb.WriteByte('\n')
+			}
+		}
+		writeIndent(sb, indent+1)
+		sb.WriteString(quoteString(f.key))
+		sb.WriteString(": ")
+		fv := rv.Field(f.index)
+		if def, ok := f.defaultValue(); ok && fv.IsZero() {
+			ft := f.typ
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if decodedFromString(ft) {
+				def = quoteString(def)
+			}
+			sb.WriteString(def)
+		} else {
+			writeExampleValue(sb, fv, indent+1, seen)
+		}
+		sb.WriteByte('\n')
+	}
+	writeIndent(sb, indent)
+	sb.WriteByte('}')
+}
+
+func writeExampleValue(sb *strings.Builder, rv reflect.Value, indent int, seen []reflect.Type) {
+	t := rv.Type()
+	if t == elemType {
+		writeElem(sb, rv.Interface().(Elem), indent)
+		return
+	}
+	if t.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv = reflect.New(t.Elem())
+		}
+		writeExampleValue(sb, rv.Elem(), indent, seen)
+		return
+	}
+	if decodedFromString(t) {
+		sb.WriteString(quoteString(formatString(rv)))
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		writeExampleStruct(sb, rv, indent, seen)
+	case reflect.Slice:
+		sb.WriteByte('[')
+		sep := ""
+		for i := 0; i < rv.Len(); i++ {
+			if isAbsentElem(rv.Index(i)) {
+				continue
+			}
+			sb.WriteString(sep)
+			writeExampleValue(sb, rv.Index(i), indent, seen)
+			sep = " "
+		}
+		sb.WriteByte(']')
+	case reflect.Map:
+		if rv.Len() == 0 || t.Key().Kind() != reflect.String {
+			sb.WriteString("{}")
+			return
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		present := keys[:0]
+		for _, k := range keys {
+			if !isAbsentElem(rv.MapIndex(k)) {
+				present = append(present, k)
+			}
+		}
+		if keys = present; len(keys) == 0 {
+			sb.WriteString("{}")
+			return
+		}
+		sb.WriteString("{\n")
+		for _, k := range keys {
+			writeIndent(sb, indent+1)
+			sb.WriteString(quoteString(k.String()))
+			sb.WriteString(": ")
+			writeExampleValue(sb, rv.MapIndex(k), indent+1, seen)
+			sb.WriteByte('\n')
+		}
+		writeIndent(sb, indent)
+		sb.WriteByte('}')
+	case reflect.Interface:
+		if rv.IsNil() {
+			sb.WriteString(`""`)
+			return
+		}
+		writeExampleValue(sb, rv.Elem(), indent, seen)
+	default:
+		sb.WriteString(`""`)
+	}
+}
+
+// isAbsentElem returns true if rv is an absent Elem, which is omitted from
+// documents as it has no value.
+func isAbsentElem(rv reflect.Value) bool {
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			t := rv.Type()
+			for t.Kind() == reflect.Pointer {
+				t = t.Elem()
+			}
+			return t == elemType
+		}
+		rv = rv.Elem()
+	}
+	return rv.Type() == elemType && rv.Interface().(Elem).IsZero()
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// formatString formats a value of a type decoded from a string, see
+// decodedFromString.
+func formatString(rv reflect.Value) string {
+	t := rv.Type()
+	if enum := lookupEnum(t); enum != nil {
+		if symbol, ok := enum.symbolValue(rv); ok {
+			return symbol
+		}
+	}
+
+	switch t {
+	case durationType:
+		return time.Duration(rv.Int()).String()
+	case timeType:
+		return rv.Interface().(time.Time).Format(time.RFC3339Nano)
+	}
+
+	if reflect.PointerTo(t).Implements(textMarshalerType) {
+		p := reflect.New(t)
+		p.Elem().Set(rv)
+		if text, err := p.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, t.Bits())
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+// writeElem writes e in Saft syntax. Association lists are written as indented
+// blocks. Absent elements have no value and are omitted from lists and
+// association lists, and e must not be absent.
+func writeElem(sb *strings.Builder, e Elem, indent int) {
+	switch x := e.any.(type) {
+	case *String:
+		sb.WriteString(quoteString(x.V))
+	case *List:
+		sb.WriteByte('[')
+		sep := ""
+		for i := range x.L {
+			if x.L[i].IsZero() {
+				continue
+			}
+			sb.WriteString(sep)
+			writeElem(sb, x.L[i], indent)
+			sep = " "
+		}
+		sb.WriteByte(']')
+	case *Assoc:
+		n := 0
+		for i := range x.L {
+			if !x.L[i].V.IsZero() {
+				n++
+			}
+		}
+		if n == 0 {
+			sb.WriteString("{}")
+			return
+		}
+		sb.WriteString("{\n")
+		for i := range x.L {
+			if x.L[i].V.IsZero() {
+				continue
+			}
+			writeIndent(sb, indent+1)
+			sb.WriteString(quoteString(x.L[i].K.V))
+			sb.WriteString(": ")
+			writeElem(sb, x.L[i].V, indent+1)
+			sb.WriteByte('\n')
+		}
+		writeIndent(sb, indent)
+		sb.WriteByte('}')
+	}
+}
+
+// quoteString returns s in symbol form if possible, otherwise in interpreted
+// form.
+func quoteString(s string) string {
+	if isSymbol(s) {
+		return s
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\\', '"':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// isSymbol returns true if s can be written in symbol form. Strings containing
+// '/' are not since "//" starts a comment.
+func isSymbol(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) || isSpace(r) || strings.ContainsRune("\\`\"{}[]:/", r) {
+			return false
+		}
+	}
+	return true
+}
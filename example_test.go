@@ -0,0 +1,105 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStructDocument(t *testing.T) {
+	want := `{
+  server: {
+    host: localhost
+    // Port to listen on
+    port: 8080
+    read-timeout: 5s
+    addr: ""
+  }
+  level: debug
+  tags: [a b]
+  labels: {}
+  ratio: 0
+  start: "0001-01-01T00:00:00Z"
+}
+`
+	if got := saft.StructDocument(decodeTestConfig{}); got != want {
+		t.Fatalf("saft.StructDocument() = \n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStructDocument_Values(t *testing.T) {
+	type config struct {
+		Name    string            `saftdoc:"Name of the service.\nMay contain spaces."`
+		Timeout time.Duration     `saft:"timeout,default=1s"`
+		Labels  map[string]string `saft:"labels"`
+	}
+	want := `{
+  // Name of the service.
+  // May contain spaces.
+  name: "a \"b\"\tc"
+  timeout: 1m30s
+  labels: {
+    "a/b": c
+    x: ""
+  }
+}
+`
+	cfg := config{
+		Name:    "a \"b\"\tc",
+		Timeout: 90 * time.Second,
+		Labels:  map[string]string{"x": "", "a/b": "c"},
+	}
+	got := saft.StructDocument(&cfg)
+	if got != want {
+		t.Fatalf("saft.StructDocument() = \n%s\nwant:\n%s", got, want)
+	}
+
+	var decoded config
+	if err := saft.Decode(getTestElem(t, got), &decoded); err != nil {
+		t.Fatalf("saft.Decode() error = %q", err)
+	}
+	if !reflect.DeepEqual(decoded, cfg) {
+		t.Fatalf("decoded example %+v; want %+v", decoded, cfg)
+	}
+}
+
+func TestStructDocument_NonStruct(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "non-struct") {
+			t.Fatalf("saft.StructDocument(1) panic = %v; want non-struct panic", r)
+		}
+	}()
+	saft.StructDocument(1)
+}
+
+func TestStructDocument_AbsentElems(t *testing.T) {
+	type config struct {
+		A saft.Elem
+		B *saft.Elem
+		C []saft.Elem
+		D map[string]saft.Elem
+		E saft.Elem `saft:"e,default=[x]"`
+	}
+	want := `{
+  c: [y]
+  d: {
+    b: z
+  }
+  e: [x]
+}
+`
+	cfg := config{
+		C: []saft.Elem{{}, getTestElem(t, "y")},
+		D: map[string]saft.Elem{"a": {}, "b": getTestElem(t, "z")},
+	}
+	if got := saft.StructDocument(&cfg); got != want {
+		t.Fatalf("saft.StructDocument() = \n%s\nwant:\n%s", got, want)
+	}
+
+	data, err := saft.MarshalAny(saft.AnyMap{{K: "a", V: []any{saft.Elem{}, "b"}}, {K: "c", V: saft.Elem{}}})
+	if want := "{\n  a: [b]\n}\n"; string(data) != want || err != nil {
+		t.Fatalf("saft.MarshalAny() = (%q, %q); want (%q, nil)", data, errorString(err), want)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/johan-bolmsjo/saft
 
+go 1.21
+
 require github.com/johan-bolmsjo/errors v1.0.0
@@ -0,0 +1,131 @@
+package saft
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/johan-bolmsjo/errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// newInput returns a rune reader decoding the input stream.
+//
+// A leading byte order mark is skipped and UTF-16 input is transcoded, see
+// detectEncoding. Returns the rune reader and the number of skipped bytes.
+func newInput(reader io.Reader, opts *ParseOptions) (io.RuneReader, int) {
+	br := bufio.NewReader(reader)
+
+	// Wait for as much input as needed to tell the encoding apart, so that the
+	// encoding doesn't depend on how the input happens to be split into reads.
+	// Shorter input is detected from what there is.
+	n := 2
+	if b, err := br.Peek(1); err == nil && b[0] == 0xef {
+		n = 3 // UTF-8 byte order mark or the lead byte of three
+	}
+	b, _ := br.Peek(n)
+
+	order, skip := detectEncoding(b)
+	br.Discard(skip)
+	if order != nil {
+		ur := &utf16Reader{r: br, order: order, strict: opts.StrictUTF8, n: int64(skip), max: opts.MaxInputBytes}
+		return ur, skip
+	}
+	return br, skip
+}
+
+// detectEncoding detects the encoding of input starting with b.
+//
+// UTF-16 input is either identified by its byte order mark or guessed from a
+// leading zero byte in the first code unit. Returns the byte order of UTF-16
+// input or nil for UTF-8, and the size of any byte order mark.
+func detectEncoding(b []byte) (order binary.ByteOrder, skip int) {
+	switch {
+	case len(b) >= 3 && b[0] == 0xef && b[1] == 0xbb && b[2] == 0xbf:
+		return nil, 3
+	case len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff:
+		return binary.BigEndian, 2
+	case len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe:
+		return binary.LittleEndian, 2
+	case len(b) >= 2 && b[0] == 0 && b[1] != 0:
+		return binary.BigEndian, 0
+	case len(b) >= 2 && b[0] != 0 && b[1] == 0:
+		return binary.LittleEndian, 0
+	}
+	return nil, 0
+}
+
+// utf16Reader transcodes UTF-16 input to runes.
+//
+// The size returned by ReadRune is the size of the rune encoded as UTF-8, so
+// that positions are byte offsets into the equivalent UTF-8 input. Invalid
+// input is returned as utf8.RuneError of size 1 like bufio.Reader does for
+// invalid UTF-8, or as an error in strict mode.
+//
+// The input size limit is enforced by the reader rather than the lexer since it
+// applies to the UTF-16 input, not the transcoded runes.
+type utf16Reader struct {
+	r      *bufio.Reader
+	order  binary.ByteOrder
+	strict bool
+	unit   [2]byte
+	n      int64 // Number of bytes read
+	max    int64 // Maximum input size or zero
+}
+
+var errInvalidUTF16 = errors.New("invalid UTF-16 encoding")
+
+func (ur *utf16Reader) readUnit() (uint16, error) {
+	if ur.max > 0 && ur.n+2 > ur.max {
+		if _, err := ur.r.Peek(1); err != nil {
+			return 0, err // The input ends within the limit
+		}
+		return 0, ErrMaxInputBytes
+	}
+	ur.n += 2
+	if _, err := io.ReadFull(ur.r, ur.unit[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errInvalidUTF16
+		}
+		return 0, err
+	}
+	return ur.order.Uint16(ur.unit[:]), nil
+}
+
+func (ur *utf16Reader) peekUnit() (uint16, bool) {
+	b, err := ur.r.Peek(2)
+	if err != nil {
+		return 0, false
+	}
+	return ur.order.Uint16(b), true
+}
+
+// ReadRune implements the io.RuneReader interface.
+func (ur *utf16Reader) ReadRune() (r rune, size int, err error) {
+	u, err := ur.readUnit()
+	if err != nil {
+		if err == errInvalidUTF16 && !ur.strict {
+			return utf8.RuneError, 1, nil
+		}
+		return 0, 0, err
+	}
+
+	r = rune(u)
+	if utf16.IsSurrogate(r) {
+		if u2, ok := ur.peekUnit(); ok {
+			if r2 := utf16.DecodeRune(r, rune(u2)); r2 != utf8.RuneError {
+				if ur.max > 0 && ur.n+2 > ur.max {
+					return 0, 0, ErrMaxInputBytes
+				}
+				ur.n += 2
+				ur.r.Discard(2)
+				return r2, utf8.RuneLen(r2), nil
+			}
+		}
+		if ur.strict {
+			return 0, 0, errInvalidUTF16
+		}
+		return utf8.RuneError, 1, nil
+	}
+	return r, utf8.RuneLen(r), nil
+}
@@ -1,26 +1,46 @@
 package saft
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/johan-bolmsjo/errors"
 	"io"
+	"math"
+	"sort"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-// lexer contains state for lexing from an io.RuneReader
+// lexer contains state for lexing from an io.RuneReader or a string
 type lexer struct {
 	rr          io.RuneReader
+	src         string    // Input if lexing from a string
+	fromSrc     bool      // Lexing from src rather than rr?
 	unreadRunes []lexRune // Uused for peeking
 	pos         LexPos    // Current line and column based on read runes
+	offset      int64     // Current byte offset, not limited like pos.Offset
+	source      *source   // Shared by the lexed strings
+	strict      bool      // Fail on invalid UTF-8
+	normCRLF    bool      // Translate CR LF to LF in raw strings
+	maxString   int       // Maximum string length or zero
+	maxInput    int64     // Maximum input size or zero
 	prevKind    lexKind   // Used to merge whitespace tokens
 	eof         bool      // EOF seen?
 	es          errors.Sink
 }
 
 // newLexer returns a new lexer lexing from the given input stream.
-func newLexer(rr io.RuneReader) *lexer {
-	return &lexer{rr: rr, pos: LexPos{Line: 1}}
+func newLexer(rr io.RuneReader, opts *ParseOptions) *lexer {
+	return &lexer{
+		rr:        rr,
+		pos:       LexPos{Line: 1},
+		source:    &source{cols: newColumns(opts)},
+		strict:    opts.StrictUTF8,
+		normCRLF:  opts.NormalizeCRLF,
+		maxString: opts.MaxStringLen,
+		maxInput:  opts.MaxInputBytes,
+	}
 }
 
 // End of file is coded using a sentinel rune value.
@@ -41,25 +61,50 @@ func (lex *lexer) readRune() lexRune {
 		return lr
 	}
 
-	lr := lexRune{runeEof, lex.pos}
+	lr := lexRune{runeEof, lex.pos, lex.offset}
 
 	// EOF is sticky (EOF set on error as well)
 	if !lex.eof {
-		r, _, err := lex.rr.ReadRune()
+		var r rune
+		var size int
+		var err error
+		if lex.fromSrc {
+			r, size, err = lex.readSrcRune()
+		} else {
+			r, size, err = lex.rr.ReadRune()
+		}
 		if err != nil {
 			lex.eof = true
 			if err != io.EOF {
 				lex.emitPosError(err, lex.pos)
 			}
+		} else if lex.maxInput > 0 && lex.offset+int64(size) > lex.maxInput {
+			lex.emitPosError(ErrMaxInputBytes, lex.pos)
+		} else if r == utf8.RuneError && size == 1 && lex.strict {
+			lex.emitPosError(errors.New("invalid UTF-8 encoding"), lex.pos)
 		} else {
 			lr.r = r
-			lex.pos.update(r)
+			lex.source.cols.advance(&lex.pos, r, size)
+			lex.offset += int64(size)
 		}
 	}
 
 	return lr
 }
 
+// readSrcRune reads the rune at the current offset of src.
+func (lex *lexer) readSrcRune() (r rune, size int, err error) {
+	i := lex.offset
+	if i >= int64(len(lex.src)) {
+		return 0, 0, io.EOF
+	}
+	if c := lex.src[i]; c < utf8.RuneSelf {
+		return rune(c), 1, nil
+	}
+	r, size = utf8.DecodeRuneInString(lex.src[i:])
+	return r, size, nil
+}
+
 func (lex *lexer) unreadRune(lr lexRune) {
 	if lr.r != runeEof {
 		lex.unreadRunes = append(lex.unreadRunes, lr)
@@ -136,6 +181,16 @@ func (lex *lexer) lexSpace(firstRune lexRune) lexToken {
 	return lexToken{k: lexKindSpace, pos: firstRune.pos}
 }
 
+// stringTooLong checks the length of a string being lexed against the limit.
+// An error is emitted if the string starting at pos is too long.
+func (lex *lexer) stringTooLong(n int, pos LexPos) bool {
+	if lex.maxString > 0 && n > lex.maxString {
+		lex.emitPosError(ErrMaxStringLen, pos)
+		return true
+	}
+	return false
+}
+
 // Check that double strings like "a""b" a"b" etc are not allowed. There must
 // always be some separator between two strings. This is caught in the lexer
 // because the parser would produce confusing errors for some cases such as a"a"
@@ -153,7 +208,7 @@ func (lex *lexer) lexInterpretedString(firstRune lexRune) lexToken {
 		return errToken
 	}
 
-	var sb strings.Builder
+	sb := lex.newStrBuf()
 	var lr lexRune
 
 	parseEscape := false
@@ -163,13 +218,13 @@ runeLoop:
 		if parseEscape {
 			switch lr.r {
 			case '\\', '"':
-				sb.WriteByte(byte(lr.r))
+				sb.writeRune(lr.r)
 			case 'n':
-				sb.WriteByte('\n')
+				sb.writeRune('\n')
 			case 'r':
-				sb.WriteByte('\r')
+				sb.writeRune('\r')
 			case 't':
-				sb.WriteByte('\t')
+				sb.writeRune('\t')
 			default:
 				lex.emitPosError(errors.New("unknown escape sequence"), lr.pos)
 				return lexToken{k: lexKindEof, pos: lex.pos}
@@ -185,9 +240,12 @@ runeLoop:
 			case '"':
 				break runeLoop
 			default:
-				sb.WriteRune(lr.r)
+				sb.add(lr)
 			}
 		}
+		if lex.stringTooLong(sb.len(), firstRune.pos) {
+			return lexToken{k: lexKindEof, pos: lex.pos}
+		}
 	}
 
 	if lr.r != '"' {
@@ -203,15 +261,21 @@ func (lex *lexer) lexRawString(firstRune lexRune) lexToken {
 		return errToken
 	}
 
-	var sb strings.Builder
+	sb := lex.newStrBuf()
 	var lr lexRune
 
 runeLoop:
 	for lr.read(lex) != runeEof {
 		if lr.r == '`' {
 			break runeLoop
+		} else if lr.r == '\r' && lex.normCRLF && lex.peekRune().r == '\n' {
+			lex.source.crs = append(lex.source.crs, lr.pos.Offset)
+			continue
 		} else {
-			sb.WriteRune(lr.r)
+			sb.add(lr)
+		}
+		if lex.stringTooLong(sb.len(), firstRune.pos) {
+			return lexToken{k: lexKindEof, pos: lex.pos}
 		}
 	}
 
@@ -234,19 +298,22 @@ func (lex *lexer) lexSymbolString(firstRune lexRune) lexToken {
 			r == '/' || r == runeEof)
 	}
 
-	var sb strings.Builder
-	sb.WriteRune(firstRune.r)
+	sb := lex.newStrBuf()
+	sb.add(firstRune)
 
 	var lr lexRune
 keepScanning:
 	for predicate(lr.read(lex)) {
-		sb.WriteRune(lr.r)
+		sb.add(lr)
+		if lex.stringTooLong(sb.len(), firstRune.pos) {
+			return lexToken{k: lexKindEof, pos: lex.pos}
+		}
 	}
 
 	// Comments need to terminate scanning but a single '/' is allowed
 	if lr.r == '/' {
 		if lex.peekRune().r != '/' {
-			sb.WriteRune(lr.r)
+			sb.add(lr)
 			goto keepScanning
 		}
 	}
@@ -255,6 +322,70 @@ keepScanning:
 	return lexToken{k: lexKindSymbolString, s: sb.String(), pos: firstRune.pos}
 }
 
+// strBuf accumulates the content of a string being lexed.
+//
+// When lexing from a string the content is sliced from the input for as long as
+// it's identical to it, and only copied once it diverges, such as at an escape
+// sequence.
+type strBuf struct {
+	src     string
+	slicing bool  // Content is src[start:end]?
+	start   int64 // Offset of content in src
+	end     int64
+	sb      strings.Builder
+}
+
+func (lex *lexer) newStrBuf() strBuf {
+	return strBuf{src: lex.src, slicing: lex.fromSrc, start: -1}
+}
+
+// add appends lr to the content as read from the input.
+func (b *strBuf) add(lr lexRune) {
+	if b.slicing {
+		if b.start < 0 {
+			b.start, b.end = lr.offset, lr.offset
+		}
+		// Invalid encoding is decoded as utf8.RuneError, which differs from
+		// the input.
+		if lr.offset == b.end && lr.r != utf8.RuneError {
+			b.end += int64(utf8.RuneLen(lr.r))
+			return
+		}
+	}
+	b.writeRune(lr.r)
+}
+
+// writeRune appends r to the content, copying from then on.
+func (b *strBuf) writeRune(r rune) {
+	if b.slicing {
+		b.slicing = false
+		if b.start >= 0 {
+			b.sb.WriteString(b.src[b.start:b.end])
+		}
+	}
+	b.sb.WriteRune(r)
+}
+
+func (b *strBuf) len() int {
+	if b.slicing {
+		if b.start < 0 {
+			return 0
+		}
+		return int(b.end - b.start)
+	}
+	return b.sb.Len()
+}
+
+func (b *strBuf) String() string {
+	if b.slicing {
+		if b.start < 0 {
+			return ""
+		}
+		return b.src[b.start:b.end]
+	}
+	return b.sb.String()
+}
+
 // lexComment scans characters until EOL or EOF.
 // The comment marker '//' has already been consumed.
 func (lex *lexer) lexComment() {
@@ -320,9 +451,23 @@ func (kind lexKind) isString() bool {
 	return kind == lexKindSymbolString || kind == lexKindInterpString || kind == lexKindRawString
 }
 
+// stringForm returns the syntax form of string tokens.
+func (kind lexKind) stringForm() StringForm {
+	switch kind {
+	case lexKindSymbolString:
+		return FormSymbol
+	case lexKindInterpString:
+		return FormInterpreted
+	case lexKindRawString:
+		return FormRaw
+	}
+	return FormUnspecified
+}
+
 type lexRune struct {
-	r   rune
-	pos LexPos
+	r      rune
+	pos    LexPos
+	offset int64 // Byte offset of the rune
 }
 
 func (lr *lexRune) read(lex *lexer) rune {
@@ -336,23 +481,135 @@ func (lr *lexRune) unread(lex *lexer) {
 }
 
 // LexPos contain line and column information of lexed runes and tokens.
+// The unit of columns is decided by ParseOptions.
 type LexPos struct {
 	Line, Column int32
+	Offset       int32 // Byte offset from the start of the input; limited to math.MaxInt32
+}
+
+// ColumnUnit is the unit columns of lexed positions are counted in.
+type ColumnUnit int8
+
+const (
+	// One column per rune with tabs expanded to the next tab stop.
+	ColumnRunes ColumnUnit = iota
+	// Columns as displayed in a terminal with tabs expanded to the next tab
+	// stop. Wide East Asian runes occupy two columns while combining marks and
+	// other zero width runes occupy none.
+	ColumnDisplay
+	// One column per byte of UTF-8 encoded input.
+	ColumnBytes
+	// One column per UTF-16 code unit as used by the language server protocol.
+	ColumnUTF16
+)
+
+const defaultTabWidth = 8
+
+// columns advances lexed positions according to the column semantics of the
+// parse options. It's kept small since parsed strings store a copy.
+type columns struct {
+	tabWidth uint8
+	unit     ColumnUnit
+}
+
+var defaultColumns = columns{tabWidth: defaultTabWidth, unit: ColumnRunes}
+
+// source holds what the strings of a parse share, which is what's needed to
+// compute positions within them, see String.PosAt. Strings not parsed from
+// a document may instead have a source of their own naming their origin.
+//
+// A source must not be modified once elements referring to it are returned.
+type source struct {
+	cols   columns
+	crs    []int32 // Offsets of CRs dropped from raw strings, ascending
+	origin string  // See String.Origin
+}
+
+var defaultSource = source{cols: defaultColumns}
+
+// originOf returns the origin of src, which may be nil.
+func (src *source) originOf() string {
+	if src == nil {
+		return ""
+	}
+	return src.origin
 }
 
-// update position based on input rune.
-func (pos *LexPos) update(r rune) {
-	switch r {
-	case '\t': // Count tabs using a 8 character tab stop
-		pos.Column += 8 - (pos.Column % 8)
-	case '\n':
+// where returns the origin of src if it has one, or pos, for error messages.
+func (src *source) where(pos *LexPos) string {
+	if origin := src.originOf(); origin != "" {
+		return origin
+	}
+	return pos.String()
+}
+
+// droppedCR returns true if a CR at offset was dropped from a raw string.
+func (src *source) droppedCR(offset int32) bool {
+	i := sort.Search(len(src.crs), func(i int) bool { return src.crs[i] >= offset })
+	return i < len(src.crs) && src.crs[i] == offset
+}
+
+func newColumns(opts *ParseOptions) columns {
+	c := columns{tabWidth: uint8(min(opts.TabWidth, math.MaxUint8)), unit: opts.Columns}
+	if opts.TabWidth <= 0 {
+		c.tabWidth = defaultTabWidth
+	}
+	return c
+}
+
+// advance position past rune r occupying size bytes of input.
+func (c *columns) advance(pos *LexPos, r rune, size int) {
+	if pos.Offset <= math.MaxInt32-int32(size) {
+		pos.Offset += int32(size)
+	} else {
+		pos.Offset = math.MaxInt32
+	}
+	switch {
+	case r == '\n':
 		pos.Line++
 		pos.Column = 0
+	case c.unit == ColumnBytes:
+		pos.Column += int32(size)
+	case c.unit == ColumnUTF16:
+		if r >= 0x10000 { // Encoded as a surrogate pair
+			pos.Column += 2
+		} else {
+			pos.Column++
+		}
+	case r == '\t': // Count tabs using the tab stop
+		tabWidth := int32(c.tabWidth)
+		pos.Column += tabWidth - (pos.Column % tabWidth)
+	case c.unit == ColumnDisplay:
+		pos.Column += int32(runeWidth(r))
 	default:
 		pos.Column++
 	}
 }
 
+// IsValid returns true if pos is the position of lexed input. The zero LexPos,
+// such as the position of elements built in code, is not valid since lines
+// start at 1.
+func (pos *LexPos) IsValid() bool {
+	return pos.Line > 0
+}
+
+// Column returns the column of the byte at offset in src as counted by a parse
+// with opts, from the start of its line. It computes columns of positions
+// lexed with other options from their offsets, such as the display column of
+// a position counted in UTF-16 code units:
+//
+//	col := saft.Column(src, int(pos.Offset), saft.ParseOptions{Columns: saft.ColumnDisplay})
+func Column(src []byte, offset int, opts ParseOptions) int {
+	cols := newColumns(&opts)
+	var pos LexPos
+	for i := bytes.LastIndexByte(src[:offset], '\n') + 1; i < offset; {
+		r, size := utf8.DecodeRune(src[i:])
+		cols.advance(&pos, r, size)
+		i += size
+	}
+	return int(pos.Column)
+}
+
 // String implements the fmt.Stringer interface.
 func (pos *LexPos) String() string {
 	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
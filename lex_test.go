@@ -2,15 +2,33 @@ package saft
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 )
 
 func lex(input string) ([]lexToken, error) {
-	var tokens []lexToken
+	return lexWithOptions(input, ParseOptions{})
+}
+
+// lexWithOptions lexes input both from a reader and from a string, returning an
+// error if the results differ.
+func lexWithOptions(input string, opts ParseOptions) ([]lexToken, error) {
+	tokens, err := lexAll(newLexer(strings.NewReader(input), &opts))
+
+	srcLex := newLexer(nil, &opts)
+	srcLex.src, srcLex.fromSrc = input, true
+	srcTokens, srcErr := lexAll(srcLex)
+	if !reflect.DeepEqual(tokens, srcTokens) || fmt.Sprint(err) != fmt.Sprint(srcErr) {
+		return nil, fmt.Errorf("string lexer mismatch:\n%s%v\nwant:\n%s%v",
+			tokensToString(srcTokens), srcErr, tokensToString(tokens), err)
+	}
+	return tokens, err
+}
 
-	lex := newLexer(strings.NewReader(input))
+func lexAll(lex *lexer) ([]lexToken, error) {
+	var tokens []lexToken
 	var done bool
 	for !done {
 		tok, err := lex.readToken()
@@ -203,3 +221,46 @@ func TestLex_TabPos(t *testing.T) {
 `)
 	checkError(t, err, "nil")
 }
+
+// Check that the tab width is configurable.
+func TestLex_TabWidth(t *testing.T) {
+	tokens, err := lexWithOptions(TAB+`1`+TAB+`123`+TAB, ParseOptions{TabWidth: 4})
+	checkTokens(t, tokens, `
+1:0: <space>
+1:4: <symbol-string> "1"
+1:5: <space>
+1:8: <symbol-string> "123"
+1:11: <space>
+1:12: <eof>
+`)
+	checkError(t, err, "nil")
+}
+
+func TestLex_ColumnUnits(t *testing.T) {
+	const input = "\"å漢😀\"\tx"
+
+	var tbl = []struct {
+		unit ColumnUnit
+		want string
+	}{
+		{ColumnRunes, "1:0: <interp-string> \"å漢😀\"\n1:5: <space>\n1:8: <symbol-string> \"x\"\n1:9: <eof>"},
+		{ColumnDisplay, "1:0: <interp-string> \"å漢😀\"\n1:7: <space>\n1:8: <symbol-string> \"x\"\n1:9: <eof>"},
+		{ColumnBytes, "1:0: <interp-string> \"å漢😀\"\n1:11: <space>\n1:12: <symbol-string> \"x\"\n1:13: <eof>"},
+		{ColumnUTF16, "1:0: <interp-string> \"å漢😀\"\n1:6: <space>\n1:7: <symbol-string> \"x\"\n1:8: <eof>"},
+	}
+
+	for _, td := range tbl {
+		tokens, err := lexWithOptions(input, ParseOptions{Columns: td.unit})
+		checkTokens(t, tokens, td.want)
+		checkError(t, err, "nil")
+	}
+}
+
+// Check that the byte offset is tracked along with line and column.
+func TestLex_Offset(t *testing.T) {
+	tokens, err := lex("å\n\tx")
+	checkError(t, err, "nil")
+	if got := tokens[2].pos; got != (LexPos{Line: 2, Column: 8, Offset: 4}) {
+		t.Fatalf("position of x = %+v; want {Line:2 Column:8 Offset:4}", got)
+	}
+}
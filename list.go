@@ -3,7 +3,13 @@ package saft
 // List is a regular list of elements.
 type List struct {
 	pos LexPos
-	L   []Elem // List with elements.
+	src *source // Origin of a list not parsed from a document or nil
+	L   []Elem  // List with elements.
+}
+
+// NewList returns a new list containing elems.
+func NewList(elems ...Elem) *List {
+	return &List{L: elems}
 }
 
 // Pos returns positional information useful for context dependent error reporting.
@@ -11,6 +17,54 @@ func (l *List) Pos() LexPos {
 	return l.pos
 }
 
-func (l *List) elemType() elemType {
-	return elemTypeList
+// Origin describes where the list came from if it was not parsed from a
+// document, see String.Origin.
+func (l *List) Origin() string {
+	return l.src.originOf()
+}
+
+func (l *List) where() string {
+	return l.src.where(&l.pos)
+}
+
+func (l *List) kind() Kind {
+	return KindList
+}
+
+// Append elements to the end of the list.
+func (l *List) Append(elems ...Elem) {
+	l.L = append(l.L, elems...)
+}
+
+// Insert elements before index i. The index may be equal to the length of the
+// list to append elements. Returns false if the index is out of range.
+func (l *List) Insert(i int, elems ...Elem) bool {
+	if i < 0 || i > len(l.L) {
+		return false
+	}
+	elems = append([]Elem(nil), elems...) // Elems may alias l.L
+	l.L = append(l.L, elems...)
+	copy(l.L[i+len(elems):], l.L[i:])
+	copy(l.L[i:], elems)
+	return true
+}
+
+// Set the element at index i. Returns false if the index is out of range.
+func (l *List) Set(i int, e Elem) bool {
+	if i < 0 || i >= len(l.L) {
+		return false
+	}
+	l.L[i] = e
+	return true
+}
+
+// Delete the element at index i. Returns false if the index is out of range.
+func (l *List) Delete(i int) bool {
+	if i < 0 || i >= len(l.L) {
+		return false
+	}
+	copy(l.L[i:], l.L[i+1:])
+	l.L[len(l.L)-1] = Elem{} // Do not keep the removed element reachable
+	l.L = l.L[:len(l.L)-1]
+	return true
 }
@@ -0,0 +1,38 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"testing"
+)
+
+func TestList_Mutate(t *testing.T) {
+	str := func(v string) saft.Elem { return saft.ElemOf(saft.NewString(v)) }
+	list := saft.NewList(str("a"))
+
+	list.Append(str("d"))
+	if !list.Insert(1, str("b"), str("c")) {
+		t.Fatalf("list.Insert(1) = false")
+	}
+	if list.Insert(-1, str("x")) {
+		t.Fatalf("list.Insert(-1) = true")
+	}
+	checkElems(t, []saft.Elem{saft.ElemOf(list)}, `["a" "b" "c" "d" ]`)
+
+	if !list.Set(0, str("x")) || list.Set(4, str("x")) {
+		t.Fatalf("list.Set() returned unexpected result")
+	}
+	if !list.Delete(3) || list.Delete(3) {
+		t.Fatalf("list.Delete() returned unexpected result")
+	}
+	checkElems(t, []saft.Elem{saft.ElemOf(list)}, `["x" "b" "c" ]`)
+}
+
+func TestList_InsertAliased(t *testing.T) {
+	str := func(v string) saft.Elem { return saft.ElemOf(saft.NewString(v)) }
+	list := saft.NewList()
+	list.L = make([]saft.Elem, 0, 8) // Room to insert without reallocating
+	list.Append(str("a"), str("b"), str("c"))
+
+	list.Insert(0, list.L[1:]...)
+	checkElems(t, []saft.Elem{saft.ElemOf(list)}, `["b" "c" "a" "b" "c" ]`)
+}
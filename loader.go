@@ -0,0 +1,172 @@
+package saft
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Loader parses many files concurrently.
+//
+// A loader is safe for concurrent use as long as its fields are not modified.
+type Loader struct {
+	FS      fs.FS        // File system to read files from; the operating system's if nil
+	Options ParseOptions // Options files are parsed with
+	Workers int          // Maximum number of files parsed at a time; GOMAXPROCS if zero
+}
+
+// File is a parsed file.
+type File struct {
+	Name  string
+	Elems []Elem
+}
+
+// FileError is the error of a file that failed to load.
+type FileError struct {
+	Name string
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *FileError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+// Cause returns the underlying error.
+func (e *FileError) Cause() error {
+	return e.Err
+}
+
+// LoadError is the error returned by Loader if one or more files failed to
+// load. It holds the error of each such file in file name order.
+type LoadError []*FileError
+
+// Error implements the error interface.
+func (e LoadError) Error() string {
+	var sb strings.Builder
+	for i, err := range e {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// Load parses the named files. Files are parsed concurrently but returned in
+// file name order, with duplicate names loaded once. If any file fails to load
+// the successfully loaded files are returned together with a LoadError holding
+// the errors of all failed files. Loading stops early if ctx is done, in which
+// case the context's error is returned.
+func (l *Loader) Load(ctx context.Context, names ...string) ([]File, error) {
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	names = uniqueStrings(names)
+
+	files := make([]File, len(names))
+	errs := make([]error, len(names))
+
+	workers := l.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				files[i].Name = names[i]
+				files[i].Elems, errs[i] = l.loadFile(names[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range names {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var loadErr LoadError
+	loaded := files[:0]
+	for i, err := range errs {
+		if err != nil {
+			loadErr = append(loadErr, &FileError{Name: names[i], Err: err})
+		} else {
+			loaded = append(loaded, files[i])
+		}
+	}
+	if loadErr != nil {
+		return loaded, loadErr
+	}
+	return loaded, nil
+}
+
+// LoadGlob is like Load but parses the files matching pattern. The syntax of
+// pattern is that of fs.Glob, or filepath.Glob if the loader has no FS.
+func (l *Loader) LoadGlob(ctx context.Context, pattern string) ([]File, error) {
+	var names []string
+	var err error
+	if l.FS != nil {
+		names, err = fs.Glob(l.FS, pattern)
+	} else {
+		names, err = filepath.Glob(pattern)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.Load(ctx, names...)
+}
+
+func (l *Loader) loadFile(name string) ([]Elem, error) {
+	var data []byte
+	var err error
+	if l.FS != nil {
+		data, err = fs.ReadFile(l.FS, name)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		// The file name is added by FileError
+		if pathErr, ok := err.(*fs.PathError); ok {
+			err = pathErr.Err
+		}
+		return nil, err
+	}
+	return ParseBytesWithOptions(data, l.Options)
+}
+
+// uniqueStrings removes adjacent duplicates from sorted strings.
+func uniqueStrings(s []string) []string {
+	if len(s) == 0 {
+		return s
+	}
+	j := 1
+	for i := 1; i < len(s); i++ {
+		if s[i] != s[j-1] {
+			s[j] = s[i]
+			j++
+		}
+	}
+	return s[:j]
+}
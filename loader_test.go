@@ -0,0 +1,125 @@
+package saft_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/johan-bolmsjo/errors"
+	"github.com/johan-bolmsjo/saft"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func testLoaderFS(n int) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for i := 0; i < n; i++ {
+		fsys[fmt.Sprintf("rules/%03d.saft", i)] = &fstest.MapFile{Data: []byte(fmt.Sprintf("{id:%d}", i))}
+	}
+	return fsys
+}
+
+func checkFiles(t *testing.T, files []saft.File, want string) {
+	t.Helper()
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Name+"="+strings.TrimSpace(elemsToString(f.Elems)))
+	}
+	if s := strings.Join(got, " "); s != want {
+		t.Fatalf("got files %s; want %s", s, want)
+	}
+}
+
+func TestLoader_Load(t *testing.T) {
+	loader := saft.Loader{FS: testLoaderFS(3), Workers: 2}
+	files, err := loader.Load(context.Background(), "rules/002.saft", "rules/000.saft", "rules/001.saft", "rules/000.saft")
+	checkError(t, "Load()", err, "nil")
+	checkFiles(t, files, `rules/000.saft={"id":"0"  } rules/001.saft={"id":"1"  } rules/002.saft={"id":"2"  }`)
+}
+
+func TestLoader_LoadGlob(t *testing.T) {
+	loader := saft.Loader{FS: testLoaderFS(12)}
+	files, err := loader.LoadGlob(context.Background(), "rules/00?.saft")
+	checkError(t, "LoadGlob()", err, "nil")
+	if len(files) != 10 || files[0].Name != "rules/000.saft" || files[9].Name != "rules/009.saft" {
+		t.Fatalf("LoadGlob() got %d files", len(files))
+	}
+
+	_, err = loader.LoadGlob(context.Background(), "[")
+	checkError(t, "LoadGlob()", err, "syntax error in pattern")
+}
+
+func TestLoader_LoadOS(t *testing.T) {
+	dir := t.TempDir()
+	for i, data := range []string{"a", "[b"} {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.saft", i)), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var loader saft.Loader
+	files, err := loader.LoadGlob(context.Background(), filepath.Join(dir, "*.saft"))
+	checkError(t, "LoadGlob()", err, filepath.Join(dir, "1.saft")+": 1:2: unterminated list")
+	checkFiles(t, files, filepath.Join(dir, "0.saft")+`="a"`)
+}
+
+func TestLoader_Errors(t *testing.T) {
+	fsys := testLoaderFS(2)
+	fsys["bad/a.saft"] = &fstest.MapFile{Data: []byte("{a:}")}
+	fsys["bad/b.saft"] = &fstest.MapFile{Data: []byte("[")}
+	loader := saft.Loader{FS: fsys}
+
+	files, err := loader.Load(context.Background(), "rules/001.saft", "bad/b.saft", "missing.saft", "bad/a.saft", "rules/000.saft")
+	checkError(t, "Load()", err, strings.Join([]string{
+		"bad/a.saft: 1:3: unterminated association list pair",
+		"bad/b.saft: 1:1: unterminated list",
+		"missing.saft: file does not exist",
+	}, "\n"))
+	checkFiles(t, files, `rules/000.saft={"id":"0"  } rules/001.saft={"id":"1"  }`)
+
+	loadErr, ok := err.(saft.LoadError)
+	if !ok || len(loadErr) != 3 {
+		t.Fatalf("Load() error = %#v; want LoadError of 3 files", err)
+	}
+	if errors.Cause(loadErr[2]) != fs.ErrNotExist {
+		t.Fatalf("Load() error cause = %v; want %v", errors.Cause(loadErr[2]), fs.ErrNotExist)
+	}
+}
+
+func TestLoader_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	loader := saft.Loader{FS: testLoaderFS(10)}
+	files, err := loader.LoadGlob(ctx, "rules/*")
+	checkError(t, "LoadGlob()", err, "context canceled")
+	if files != nil {
+		t.Fatalf("LoadGlob() got %d files; want none", len(files))
+	}
+}
+
+// Run with the race detector to check that loaders are safe for concurrent use.
+func TestLoader_Concurrent(t *testing.T) {
+	loader := saft.Loader{FS: testLoaderFS(200), Workers: 4}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			files, err := loader.LoadGlob(context.Background(), "rules/*.saft")
+			if err != nil || len(files) != 200 {
+				t.Errorf("LoadGlob() got %d files, error %v", len(files), err)
+				return
+			}
+			for j, f := range files {
+				if want := fmt.Sprintf("rules/%03d.saft", j); f.Name != want {
+					t.Errorf("LoadGlob() got file %s; want %s", f.Name, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,207 @@
+package saft
+
+import (
+	"fmt"
+	"github.com/johan-bolmsjo/errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Parse string as IP address.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Addr() (addr netip.Addr, err error) {
+	if addr, err = netip.ParseAddr(s.V); err != nil {
+		// netip parse error contain source string
+		err = errors.Wrap(err, s.where())
+	}
+	return
+}
+
+// Parse string as CIDR notation IP address and prefix length.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Prefix() (prefix netip.Prefix, err error) {
+	if prefix, err = netip.ParsePrefix(s.V); err != nil {
+		// netip parse error contain source string
+		err = errors.Wrap(err, s.where())
+	}
+	return
+}
+
+// Parse string as IP address and port such as "10.0.0.1:80" or "[::1]:80".
+// Returns the parsed value or an error containing positional information.
+func (s *String) AddrPort() (addrPort netip.AddrPort, err error) {
+	if addrPort, err = netip.ParseAddrPort(s.V); err != nil {
+		// netip parse error contain source string
+		err = errors.Wrap(err, s.where())
+	}
+	return
+}
+
+// Parse string as host name or IP address and port such as "example.com:80".
+// The port may be left out if defaultPort is not zero, in which case
+// defaultPort is returned. IPv6 addresses must be enclosed in brackets if
+// followed by a port.
+// Returns the parsed values or an error containing positional information.
+func (s *String) HostPort(defaultPort uint16) (host string, port uint16, err error) {
+	if defaultPort != 0 {
+		if h, ok := hostWithoutPort(s.V); ok {
+			if h == "" {
+				return "", 0, fmt.Errorf("%s: missing host: %s", s.where(), s.V)
+			}
+			return h, defaultPort, nil
+		}
+	}
+
+	h, p, err := net.SplitHostPort(s.V)
+	if err != nil {
+		// net error contain source string
+		return "", 0, errors.Wrap(err, s.where())
+	}
+	if h == "" {
+		return "", 0, fmt.Errorf("%s: missing host: %s", s.where(), s.V)
+	}
+	v, err := strconv.ParseUint(p, 10, 16)
+	if err != nil || v == 0 {
+		return "", 0, fmt.Errorf("%s: invalid port %q: %s", s.where(), p, s.V)
+	}
+	return h, uint16(v), nil
+}
+
+// hostWithoutPort returns the host of s if s does not contain a port.
+func hostWithoutPort(s string) (string, bool) {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		return s[1 : len(s)-1], true
+	}
+	switch strings.Count(s, ":") {
+	case 0:
+		return s, true
+	case 1:
+		return "", false
+	}
+	// Bare IPv6 address
+	if _, err := netip.ParseAddr(s); err == nil {
+		return s, true
+	}
+	return "", false
+}
+
+// Parse string as an absolute URL. The scheme of the URL must be one of schemes
+// unless schemes is empty. Schemes are compared case insensitively.
+// Returns the parsed value or an error containing positional information.
+func (s *String) URL(schemes ...string) (*url.URL, error) {
+	u, err := url.Parse(s.V)
+	if err != nil {
+		// url error contain source string
+		return nil, errors.Wrap(err, s.where())
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("%s: missing URL scheme: %s", s.where(), s.V)
+	}
+	if len(schemes) == 0 {
+		return u, nil
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: URL scheme %q not allowed, expected one of: %s",
+		s.where(), u.Scheme, strings.Join(schemes, ", "))
+}
+
+// IPRange is an inclusive range of IP addresses of the same family.
+type IPRange struct {
+	From, To netip.Addr
+}
+
+// Contains returns true if addr is within the range.
+func (r IPRange) Contains(addr netip.Addr) bool {
+	return r.From.Compare(addr) <= 0 && addr.Compare(r.To) <= 0
+}
+
+// String implements the fmt.Stringer interface.
+func (r IPRange) String() string {
+	return r.From.String() + "-" + r.To.String()
+}
+
+// Parse string as an inclusive range of IP addresses such as
+// "10.0.0.1-10.0.0.50". A single address is parsed as a range containing only
+// that address.
+// Returns the parsed range or an error containing positional information.
+func (s *String) IPRange() (r IPRange, err error) {
+	from, to := s.V, s.V
+	if i := strings.IndexByte(s.V, '-'); i >= 0 {
+		from, to = s.V[:i], s.V[i+1:]
+	}
+	if r.From, err = netip.ParseAddr(from); err != nil {
+		return IPRange{}, errors.Wrap(err, s.where())
+	}
+	if r.To, err = netip.ParseAddr(to); err != nil {
+		return IPRange{}, errors.Wrap(err, s.where())
+	}
+	if r.From.Is4() != r.To.Is4() {
+		return IPRange{}, fmt.Errorf("%s: invalid IP range %s: mixed address families", s.where(), s.V)
+	}
+	if r.From.Compare(r.To) > 0 {
+		return IPRange{}, fmt.Errorf("%s: invalid IP range %s: start greater than end", s.where(), s.V)
+	}
+	return r, nil
+}
+
+// Prefixes parses each element of the list as CIDR notation IP address and
+// prefix length.
+// Returns the parsed values or an error containing positional information.
+func (l *List) Prefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(l.L))
+	for _, e := range l.L {
+		s, err := e.ExpectString()
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := s.Prefix()
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// PrefixSet is like Prefixes but returns the prefixes masked and requires
+// that no two prefixes overlap.
+// Returns the parsed values or an error containing positional information.
+func (l *List) PrefixSet() ([]netip.Prefix, error) {
+	prefixes, err := l.Prefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Overlapping prefixes are adjacent when sorted by address and length.
+	order := make([]int, len(prefixes))
+	for i := range prefixes {
+		prefixes[i] = prefixes[i].Masked()
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		p, q := prefixes[order[i]], prefixes[order[j]]
+		if c := p.Addr().Compare(q.Addr()); c != 0 {
+			return c < 0
+		}
+		return p.Bits() < q.Bits()
+	})
+	for i := 1; i < len(order); i++ {
+		a, b := order[i-1], order[i]
+		if prefixes[a].Overlaps(prefixes[b]) {
+			if a > b {
+				a, b = b, a
+			}
+			posA, posB := l.L[a].Pos(), l.L[b].Pos()
+			return nil, fmt.Errorf("%s: prefix %s overlaps %s at %s", &posB, prefixes[b], prefixes[a], &posA)
+		}
+	}
+	return prefixes, nil
+}
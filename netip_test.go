@@ -0,0 +1,105 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"net/netip"
+	"testing"
+)
+
+func TestString_Addr(t *testing.T) {
+	v, err := (&saft.String{V: "10.0.0.1"}).Addr()
+	if v != netip.MustParseAddr("10.0.0.1") || err != nil {
+		t.Fatalf(`"10.0.0.1".Addr() = (%v, %q); want (10.0.0.1, nil)`, v, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "x"}).Addr()
+	checkError(t, `"x".Addr()`, err, `0:0: ParseAddr("x"): unable to parse IP`)
+}
+
+func TestString_Prefix(t *testing.T) {
+	v, err := (&saft.String{V: "10.0.0.0/8"}).Prefix()
+	if v != netip.MustParsePrefix("10.0.0.0/8") || err != nil {
+		t.Fatalf(`"10.0.0.0/8".Prefix() = (%v, %q); want (10.0.0.0/8, nil)`, v, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "10.0.0.0/33"}).Prefix()
+	checkError(t, `"10.0.0.0/33".Prefix()`, err, `0:0: netip.ParsePrefix("10.0.0.0/33"): prefix length out of range`)
+}
+
+func TestString_AddrPort(t *testing.T) {
+	v, err := (&saft.String{V: "[::1]:80"}).AddrPort()
+	if v != netip.MustParseAddrPort("[::1]:80") || err != nil {
+		t.Fatalf(`"[::1]:80".AddrPort() = (%v, %q); want ([::1]:80, nil)`, v, errorString(err))
+	}
+}
+
+func TestString_HostPort(t *testing.T) {
+	var tbl = []struct {
+		input       string
+		defaultPort uint16
+		host        string
+		port        uint16
+		error       string
+	}{
+		{"example.com:8080", 0, "example.com", 8080, "nil"},
+		{"example.com", 80, "example.com", 80, "nil"},
+		{"[::1]:8080", 80, "::1", 8080, "nil"},
+		{"[::1]", 80, "::1", 80, "nil"},
+		{"::1", 80, "::1", 80, "nil"},
+		{"example.com", 0, "", 0, "0:0: address example.com: missing port in address"},
+		{"example.com:x", 0, "", 0, `0:0: invalid port "x": example.com:x`},
+		{":80", 0, "", 0, "0:0: missing host: :80"},
+	}
+
+	for _, td := range tbl {
+		host, port, err := (&saft.String{V: td.input}).HostPort(td.defaultPort)
+		checkError(t, td.input+".HostPort()", err, td.error)
+		if host != td.host || port != td.port {
+			t.Fatalf("%q.HostPort(%d) = (%q, %d); want (%q, %d)", td.input, td.defaultPort, host, port, td.host, td.port)
+		}
+	}
+}
+
+func TestString_URL(t *testing.T) {
+	u, err := (&saft.String{V: "https://example.com/x"}).URL("http", "https")
+	if err != nil || u.Host != "example.com" {
+		t.Fatalf(`"https://example.com/x".URL() = (%v, %q)`, u, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "ftp://example.com"}).URL("http", "https")
+	checkError(t, `"ftp://example.com".URL()`, err, `0:0: URL scheme "ftp" not allowed, expected one of: http, https`)
+
+	_, err = (&saft.String{V: "example.com"}).URL()
+	checkError(t, `"example.com".URL()`, err, "0:0: missing URL scheme: example.com")
+}
+
+func TestString_IPRange(t *testing.T) {
+	r, err := (&saft.String{V: "10.0.0.1-10.0.0.50"}).IPRange()
+	checkError(t, `"10.0.0.1-10.0.0.50".IPRange()`, err, "nil")
+	if !r.Contains(netip.MustParseAddr("10.0.0.50")) || r.Contains(netip.MustParseAddr("10.0.0.51")) {
+		t.Fatalf("%s.Contains() returned unexpected result", r)
+	}
+
+	_, err = (&saft.String{V: "10.0.0.50-10.0.0.1"}).IPRange()
+	checkError(t, `"10.0.0.50-10.0.0.1".IPRange()`, err, "0:0: invalid IP range 10.0.0.50-10.0.0.1: start greater than end")
+
+	_, err = (&saft.String{V: "10.0.0.1-::1"}).IPRange()
+	checkError(t, `"10.0.0.1-::1".IPRange()`, err, "0:0: invalid IP range 10.0.0.1-::1: mixed address families")
+}
+
+func TestList_PrefixSet(t *testing.T) {
+	list, _ := getTestElem(t, `[10.0.0.0/8 192.168.1.0/24 "::/64"]`).ExpectList()
+	prefixes, err := list.PrefixSet()
+	checkError(t, "list.PrefixSet()", err, "nil")
+	if len(prefixes) != 3 {
+		t.Fatalf("list.PrefixSet() = %v", prefixes)
+	}
+
+	list, _ = getTestElem(t, `[10.1.0.0/16 192.168.1.0/24 10.0.0.1/8]`).ExpectList()
+	_, err = list.PrefixSet()
+	checkError(t, "list.PrefixSet()", err, "1:28: prefix 10.0.0.0/8 overlaps 10.1.0.0/16 at 1:1")
+
+	list, _ = getTestElem(t, `[10.0.0.0/8 [x]]`).ExpectList()
+	_, err = list.Prefixes()
+	checkError(t, "list.Prefixes()", err, "1:12: expected string, found list")
+}
@@ -0,0 +1,233 @@
+package saft
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Overlay overrides settings of a document with environment variables and
+// command-line flags. The effective value of a setting is that of its flag if
+// given, otherwise that of its environment variable if set, otherwise the value
+// in the document.
+//
+// Each setting is identified by a path of keys such as {"server", "port"},
+// which gives the flag name "server.port" and the environment variable name
+// "APP_SERVER_PORT" for the prefix "APP". Overridden values are strings, or
+// lists of strings for list settings, whose origin names the flag or
+// environment variable, see String.Origin. So do association lists created
+// for overridden values.
+type Overlay struct {
+	EnvPrefix string // Prefix of environment variable names
+	settings  []*overlaySetting
+}
+
+type overlaySetting struct {
+	path    Path
+	list    bool // Value is a comma separated list?
+	flagged bool // Value given by flag?
+	value   string
+}
+
+// Add a setting. Adding a path more than once has no effect.
+func (o *Overlay) Add(path Path) {
+	o.add(path, false)
+}
+
+// AddList adds a setting whose value is a list of strings, given as comma
+// separated strings such as "a,b,c". An empty value is an empty list. Adding a
+// path more than once has no effect.
+func (o *Overlay) AddList(path Path) {
+	o.add(path, true)
+}
+
+func (o *Overlay) add(path Path, list bool) {
+	for _, s := range o.settings {
+		if s.path.String() == path.String() {
+			return
+		}
+	}
+	o.settings = append(o.settings, &overlaySetting{path: append(Path(nil), path...), list: list})
+}
+
+// AddStruct adds a setting for each field of the struct v, or the struct v
+// points to, that can be decoded from a string. Slices of such values add list
+// settings, see AddList. Nested structs add settings of their fields below the
+// key of the struct. Keys are given by field tags, such as `saft:"port"`, or
+// are the field names with the first letter in lower case.
+//
+// Fields that can't be decoded from strings or lists of strings, such as maps
+// and slices of structs, have no setting and can only be set by the document.
+func (o *Overlay) AddStruct(v interface{}) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("saft: AddStruct of non-struct type %v", t))
+	}
+	o.addStruct(nil, t, nil)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func (o *Overlay) addStruct(path Path, t reflect.Type, seen []reflect.Type) {
+	for _, s := range seen {
+		if s == t {
+			return // Recursive type
+		}
+	}
+	seen = append(seen, t)
+
+	for _, f := range structFields(t) {
+		ft := f.typ
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		fieldPath := append(path[:len(path):len(path)], f.key)
+		switch {
+		case reflect.PointerTo(ft).Implements(textUnmarshalerType):
+			o.Add(fieldPath)
+		case ft.Kind() == reflect.Struct:
+			o.addStruct(fieldPath, ft, seen)
+		case ft.Kind() == reflect.Slice && overlayListElem(ft.Elem()):
+			o.AddList(fieldPath)
+		case ft.Kind() == reflect.Slice, ft.Kind() == reflect.Array, ft.Kind() == reflect.Map,
+			ft.Kind() == reflect.Interface, ft.Kind() == reflect.Func, ft.Kind() == reflect.Chan:
+		default:
+			o.Add(fieldPath)
+		}
+	}
+}
+
+// overlayListElem returns true if slices with elements of type t can be given
+// as list settings.
+func overlayListElem(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return decodedFromString(t)
+}
+
+// AddElem adds a setting for each string value in the association list e and
+// association lists nested in it. Lists are skipped.
+func (o *Overlay) AddElem(e Elem) {
+	o.addElem(nil, e)
+}
+
+func (o *Overlay) addElem(path Path, e Elem) {
+	switch x := e.any.(type) {
+	case *String:
+		if path != nil {
+			o.Add(path)
+		}
+	case *Assoc:
+		for i := range x.L {
+			o.addElem(append(path[:len(path):len(path)], x.L[i].K.V), x.L[i].V)
+		}
+	}
+}
+
+// flagName returns the flag name of path.
+func flagName(path Path) string {
+	return path.String()
+}
+
+// envName returns the environment variable name of path. Letters are made upper
+// case and other characters than letters and digits are replaced by
+// underscores.
+func (o *Overlay) envName(path Path) string {
+	name := strings.Join(path, "_")
+	if o.EnvPrefix != "" {
+		name = o.EnvPrefix + "_" + name
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// RegisterFlags defines a flag for each setting in fs.
+func (o *Overlay) RegisterFlags(fs *flag.FlagSet) {
+	for _, s := range o.settings {
+		s := s
+		usage := fmt.Sprintf("override %s (environment variable %s)", flagName(s.path), o.envName(s.path))
+		if s.list {
+			usage = fmt.Sprintf("override %s with comma separated values (environment variable %s)", flagName(s.path), o.envName(s.path))
+		}
+		fs.Func(flagName(s.path), usage, func(v string) error {
+			s.flagged, s.value = true, v
+			return nil
+		})
+	}
+}
+
+// Apply overrides settings of the document e given by flags or environment
+// variables, looked up using lookupEnv or os.LookupEnv if nil. Association lists
+// are created for keys of overridden settings missing in e, including e itself
+// if it's absent, with the origin of the setting. Returns an error if a key of a
+// setting is not an association list in e.
+func (o *Overlay) Apply(e *Elem, lookupEnv func(key string) (string, bool)) error {
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	for _, s := range o.settings {
+		value, src := s.value, &source{origin: "flag --" + flagName(s.path)}
+		if !s.flagged {
+			env := o.envName(s.path)
+			var ok bool
+			if value, ok = lookupEnv(env); !ok {
+				continue
+			}
+			src = &source{origin: "env " + env}
+		}
+		v := Elem{&String{src: src, V: value}}
+		if s.list {
+			list := &List{src: src}
+			if value != "" {
+				for _, item := range strings.Split(value, ",") {
+					list.L = append(list.L, Elem{&String{src: src, V: item}})
+				}
+			}
+			v = Elem{list}
+		}
+		if err := overlaySet(e, s.path, v, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overlaySet sets the value at path in e to v. Association lists created for
+// missing keys get src.
+func overlaySet(e *Elem, path Path, v Elem, src *source) error {
+	if e.IsZero() {
+		*e = Elem{&Assoc{src: src}}
+	}
+	cur := *e
+	for i, key := range path {
+		assoc, err := cur.ExpectAssoc()
+		if err != nil {
+			return fmt.Errorf("%s (overriding %s)", err, path)
+		}
+		if i == len(path)-1 {
+			assoc.L.Set(key, v)
+			break
+		}
+		j := assoc.L.index(key)
+		if j < 0 {
+			assoc.L.Add(key, Elem{&Assoc{src: src}})
+			j = len(assoc.L) - 1
+		}
+		cur = assoc.L[j].V
+	}
+	return nil
+}
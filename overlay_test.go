@@ -0,0 +1,146 @@
+package saft_test
+
+import (
+	"flag"
+	"github.com/johan-bolmsjo/saft"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+type overlayTestConfig struct {
+	Server struct {
+		Host    string
+		Port    int           `saft:"port"`
+		Timeout time.Duration `saft:"read-timeout,default=5s"`
+		Addr    netip.Addr
+	} `saft:"server"`
+	Tags    []string
+	Peers   []struct{ Host string }
+	Debug   *bool
+	Ignored string `saft:"-"`
+	private string
+}
+
+func overlayFlagNames(fs *flag.FlagSet) string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	return strings.Join(names, " ")
+}
+
+func TestOverlay_AddStruct(t *testing.T) {
+	overlay := saft.Overlay{EnvPrefix: "APP"}
+	overlay.AddStruct(&overlayTestConfig{})
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overlay.RegisterFlags(fs)
+
+	if got, want := overlayFlagNames(fs), "debug server.addr server.host server.port server.read-timeout tags"; got != want {
+		t.Fatalf("got flags %q; want %q", got, want)
+	}
+	if got, want := fs.Lookup("server.read-timeout").Usage, "override server.read-timeout (environment variable APP_SERVER_READ_TIMEOUT)"; got != want {
+		t.Fatalf("got usage %q; want %q", got, want)
+	}
+	if got, want := fs.Lookup("tags").Usage, "override tags with comma separated values (environment variable APP_TAGS)"; got != want {
+		t.Fatalf("got usage %q; want %q", got, want)
+	}
+}
+
+func TestOverlay_AddElem(t *testing.T) {
+	var overlay saft.Overlay
+	overlay.AddElem(getTestElem(t, "{a:b c:{d:e f:[g]} a:h}"))
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overlay.RegisterFlags(fs)
+
+	if got, want := overlayFlagNames(fs), "a c.d"; got != want {
+		t.Fatalf("got flags %q; want %q", got, want)
+	}
+}
+
+func TestOverlay_Apply(t *testing.T) {
+	overlay := saft.Overlay{EnvPrefix: "APP"}
+	overlay.Add(saft.Path{"server", "host"})
+	overlay.Add(saft.Path{"server", "port"})
+	overlay.Add(saft.Path{"log", "level"})
+	overlay.Add(saft.Path{"name"})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overlay.RegisterFlags(fs)
+	checkError(t, "Parse()", fs.Parse([]string{"--server.port=8080"}), "nil")
+
+	env := map[string]string{
+		"APP_SERVER_PORT": "9090",
+		"APP_LOG_LEVEL":   "debug",
+		"APP_SERVER_HOST": "example.com",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	e := getTestElem(t, "{server:{host:localhost port:80} name:app}")
+	checkError(t, "Apply()", overlay.Apply(&e, lookupEnv), "nil")
+	checkElems(t, []saft.Elem{e}, `{"server":{"host":"example.com"  "port":"8080"  } "name":"app"  "log":{"level":"debug"  } }`)
+
+	port, _ := e.Lookup(saft.Path{"server", "port"}).ExpectString()
+	if got, want := port.Origin(), "flag --server.port"; got != want {
+		t.Fatalf("got origin %q; want %q", got, want)
+	}
+	name, _ := e.Get("name").ExpectString()
+	if got := name.Origin(); got != "" {
+		t.Fatalf("got origin %q of parsed string; want none", got)
+	}
+
+	// Errors refer to the origin of values.
+	env["APP_LOG_LEVEL"] = "[x]"
+	env["APP_SERVER_HOST"] = "x"
+	checkError(t, "Apply()", overlay.Apply(&e, lookupEnv), "nil")
+	host, _ := e.Lookup(saft.Path{"server", "host"}).ExpectString()
+	_, err := host.Int64()
+	checkError(t, "Int64()", err, `env APP_SERVER_HOST: strconv.ParseInt: parsing "x": invalid syntax`)
+	_, err = e.Get("log").Get("level").ExpectList()
+	checkError(t, "ExpectList()", err, "env APP_LOG_LEVEL: expected list, found string")
+
+	// Absent documents are created.
+	var absent saft.Elem
+	checkError(t, "Apply()", overlay.Apply(&absent, lookupEnv), "nil")
+	checkElems(t, []saft.Elem{absent}, `{"server":{"host":"x"  "port":"8080"  } "log":{"level":"[x]"  } }`)
+	_, err = absent.Get("name").ExpectString()
+	checkError(t, "ExpectString()", err, `env APP_SERVER_HOST: missing value for key "name"`)
+	_, err = absent.Get("log").Get("file").ExpectString()
+	checkError(t, "ExpectString()", err, `env APP_LOG_LEVEL: missing value for key "file"`)
+
+	e = getTestElem(t, "{server:[a]}")
+	checkError(t, "Apply()", overlay.Apply(&e, lookupEnv), "1:8: expected association list, found list (overriding server.host)")
+}
+
+func TestOverlay_ApplyList(t *testing.T) {
+	var overlay saft.Overlay
+	overlay.AddList(saft.Path{"tags"})
+	overlay.AddList(saft.Path{"hosts"})
+	overlay.AddList(saft.Path{"ports"})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overlay.RegisterFlags(fs)
+	checkError(t, "Parse()", fs.Parse([]string{"--tags=a,b", "--hosts="}), "nil")
+	env := map[string]string{"PORTS": "80"}
+	lookupEnv := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	e := getTestElem(t, "{tags:[x] hosts:[y]}")
+	checkError(t, "Apply()", overlay.Apply(&e, lookupEnv), "nil")
+	checkElems(t, []saft.Elem{e}, `{"tags":["a" "b" ] "hosts":[] "ports":["80" ] }`)
+
+	_, err := e.Get("tags").Index(2).ExpectString()
+	checkError(t, "ExpectString()", err, "flag --tags: missing list element 2")
+	_, err = e.Get("ports").ExpectAssoc()
+	checkError(t, "ExpectAssoc()", err, "env PORTS: expected association list, found list")
+
+	var cfg struct{ Tags []string }
+	checkError(t, "Decode()", saft.Decode(e.Get("tags"), &cfg.Tags), "nil")
+	if len(cfg.Tags) != 2 || cfg.Tags[1] != "b" {
+		t.Fatalf("decoded tags %q; want [a b]", cfg.Tags)
+	}
+}
@@ -1,14 +1,92 @@
 package saft
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"github.com/johan-bolmsjo/errors"
 	"io"
+	"unsafe"
 )
 
 // Parse Saft document from reader. Zero or more root objects are returned in a slice.
 func Parse(reader io.Reader) ([]Elem, error) {
-	parser := newParser(reader)
+	return ParseWithOptions(reader, ParseOptions{})
+}
+
+// ParseOptions control how documents are parsed.
+// The zero value gives the behaviour of Parse.
+//
+// A leading byte order mark is always skipped. UTF-16 input is detected by
+// its byte order mark or a leading zero byte and transcoded to UTF-8. Lexed
+// positions then refer to the transcoded input.
+//
+// The limits protect against hostile documents exhausting stack or memory. A
+// limit of zero means no limit. Exceeding a limit fails parsing with the
+// corresponding Err variable wrapped with positional information; use
+// errors.Cause to identify it.
+//
+// Interning and compact allocation reduce the memory footprint of large
+// documents, typically having many repeated keys. Compact allocation places
+// elements in shared blocks rather than allocating them one by one, with the
+// caveat that a block is retained for as long as any element in it is
+// referenced. Parsed elements are used the same way regardless of options.
+type ParseOptions struct {
+	TabWidth      int        // Distance between tab stops; 8 if zero, at most 255
+	Columns       ColumnUnit // Unit columns of lexed positions are counted in
+	StrictUTF8    bool       // Fail on invalid encoding rather than decoding it as U+FFFD
+	NormalizeCRLF bool       // Translate CR LF to LF in raw strings
+
+	MaxDepth      int   // Maximum nesting depth of lists and association lists
+	MaxStringLen  int   // Maximum length of strings in bytes
+	MaxElems      int   // Maximum total number of elements
+	MaxInputBytes int64 // Maximum size of the input in bytes as read, before transcoding UTF-16
+	MaxRootElems  int   // Maximum number of root elements
+
+	InternKeys    bool // Let equal keys of association lists share memory
+	InternStrings bool // Let equal string values share memory
+	Compact       bool // Allocate elements in blocks, see below
+}
+
+// Errors returned when exceeding the limits of ParseOptions.
+var (
+	ErrMaxDepth      = errors.New("maximum nesting depth exceeded")
+	ErrMaxStringLen  = errors.New("maximum string length exceeded")
+	ErrMaxElems      = errors.New("maximum number of elements exceeded")
+	ErrMaxInputBytes = errors.New("maximum input size exceeded")
+	ErrMaxRootElems  = errors.New("maximum number of root elements exceeded")
+)
+
+// ParseWithOptions is like Parse but controlled by options.
+func ParseWithOptions(reader io.Reader, opts ParseOptions) ([]Elem, error) {
+	parser := newParser(reader, &opts)
+	if elems := parser.parseRoot(); parser.err() == nil {
+		return elems, nil
+	}
+	return nil, parser.err()
+}
+
+// ParseBytes is like Parse but parses a document held in memory.
+//
+// This is faster and allocates less than Parse since the data is not copied.
+// Strings not containing escape sequences refer to data rather than being
+// allocated separately. The data must therefore not be modified for as long as
+// the parsed elements are in use, and it's retained for as long as such strings
+// are.
+func ParseBytes(data []byte) ([]Elem, error) {
+	return ParseBytesWithOptions(data, ParseOptions{})
+}
+
+// ParseBytesWithOptions is like ParseBytes but controlled by options.
+func ParseBytesWithOptions(data []byte, opts ParseOptions) ([]Elem, error) {
+	order, skip := detectEncoding(data)
+	if order != nil {
+		// Positions refer to transcoded input, which the reader path handles.
+		return ParseWithOptions(bytes.NewReader(data), opts)
+	}
+
+	parser := &parser{lexer: newLexer(nil, &opts), opts: &opts, alloc: newAllocator(&opts, false)}
+	parser.lexer.src, parser.lexer.fromSrc = unsafe.String(unsafe.SliceData(data), len(data)), true
+	parser.lexer.pos.Offset, parser.lexer.offset = int32(skip), int64(skip)
 	if elems := parser.parseRoot(); parser.err() == nil {
 		return elems, nil
 	}
@@ -18,63 +96,111 @@ func Parse(reader io.Reader) ([]Elem, error) {
 type parser struct {
 	lexer      *lexer
 	next, prev lexToken
+	lexed      bool // Next token has been lexed?
 	es         errors.Sink
+	ctx        context.Context // Checked between tokens unless nil
+	opts       *ParseOptions
+	depth      int // Current nesting depth
+	elems      int // Number of parsed elements
+	roots      int // Number of parsed root elements
+	alloc      allocator
+	strs       map[string]string // Interned strings
+	elemStack  []Elem            // Elements of lists being parsed
+	pairStack  []Pair            // Pairs of association lists being parsed
 }
 
-func newParser(reader io.Reader) *parser {
-	return &parser{lexer: newLexer(bufio.NewReader(reader))}
+func newParser(reader io.Reader, opts *ParseOptions) *parser {
+	rr, skipped := newInput(reader, opts)
+	lexer := newLexer(rr, opts)
+	lexer.pos.Offset, lexer.offset = int32(skipped), int64(skipped)
+	if _, ok := rr.(*utf16Reader); ok {
+		lexer.maxInput = 0 // Enforced by the reader
+	}
+	return &parser{lexer: lexer, opts: opts, alloc: newAllocator(opts, true)}
 }
 
 // Top level parser.
 func (p *parser) parseRoot() []Elem {
-	// Seed parser with the first lex token.
-	p.next = p.lex()
-
 	var elems []Elem
-loop:
+	for elem, ok := p.parseRootElem(); ok; elem, ok = p.parseRootElem() {
+		elems = append(elems, elem)
+	}
+	return elems
+}
+
+// Parse the next root element.
+// Returns false at the end of the input or on error.
+func (p *parser) parseRootElem() (Elem, bool) {
 	for p.es.Ok() {
+		if p.accept(lexKindSpace) {
+			continue
+		}
+		if p.is(lexKindEof) {
+			break
+		}
+		if p.opts.MaxRootElems > 0 && p.roots >= p.opts.MaxRootElems {
+			p.posError(ErrMaxRootElems, p.peek().pos)
+			break
+		}
+
+		var elem Elem
 		switch {
-		case p.accept(lexKindSpace):
 		case p.isP((*lexToken).isString):
-			elems = append(elems, Elem{p.parseString()})
+			elem = Elem{p.parseString()}
 		case p.is(lexKindLBracket):
-			elems = append(elems, Elem{p.parseList()})
+			elem = Elem{p.parseList()}
 		case p.is(lexKindLBrace):
-			elems = append(elems, Elem{p.parseAssoc()})
-		case p.is(lexKindEof):
-			break loop
+			elem = Elem{p.parseAssoc()}
 		default:
-			p.posError(errors.New("expected string, list or association list"), p.next.pos)
+			p.posError(errors.New("expected string, list or association list"), p.peek().pos)
+			continue
 		}
+		p.roots++
+		return elem, p.es.Ok()
 	}
-	return elems
+	return Elem{}, false
 }
 
 func (p *parser) parseString() *String {
 	p.consume() // Already matched as string
-	return &String{pos: p.prev.pos, V: p.prev.s}
+	p.countElem()
+	s := p.alloc.newString()
+	*s = String{pos: p.prev.pos, form: p.prev.k.stringForm(), src: p.lexer.source, V: p.str(p.prev.s, p.opts.InternStrings)}
+	return s
 }
 
 func (p *parser) parseList() *List {
 	p.consume() // Already matched as list
-	list := &List{pos: p.prev.pos}
+	list := p.alloc.newList()
+	list.pos = p.prev.pos
+	p.countElem()
+	p.enter()
+	defer p.leave()
+
+	// Elements are collected on a stack shared by all lists to allocate a
+	// slice of the right size once done.
+	mark := len(p.elemStack)
+	defer func() {
+		list.L = p.alloc.elemSlice(p.elemStack[mark:])
+		p.elemStack = p.elemStack[:mark]
+	}()
 
 loop:
 	for p.es.Ok() {
 		switch {
 		case p.accept(lexKindSpace):
 		case p.isP((*lexToken).isString):
-			list.L = append(list.L, Elem{p.parseString()})
+			p.elemStack = append(p.elemStack, Elem{p.parseString()})
 		case p.is(lexKindLBracket):
-			list.L = append(list.L, Elem{p.parseList()})
+			p.elemStack = append(p.elemStack, Elem{p.parseList()})
 		case p.is(lexKindLBrace):
-			list.L = append(list.L, Elem{p.parseAssoc()})
+			p.elemStack = append(p.elemStack, Elem{p.parseAssoc()})
 		case p.accept(lexKindRBracket):
 			break loop
 		case p.is(lexKindEof):
-			p.posError(errors.New("unterminated list"), p.next.pos)
+			p.posError(errors.New("unterminated list"), p.peek().pos)
 		default:
-			p.posError(errors.New("expected string, list or association list"), p.next.pos)
+			p.posError(errors.New("expected string, list or association list"), p.peek().pos)
 		}
 	}
 
@@ -83,7 +209,18 @@ loop:
 
 func (p *parser) parseAssoc() *Assoc {
 	p.consume() // Already matched as association list
-	assoc := &Assoc{pos: p.prev.pos}
+	assoc := p.alloc.newAssoc()
+	assoc.pos = p.prev.pos
+	p.countElem()
+	p.enter()
+	defer p.leave()
+
+	// Pairs are collected like list elements, see parseList.
+	mark := len(p.pairStack)
+	defer func() {
+		assoc.L = p.alloc.pairSlice(p.pairStack[mark:])
+		p.pairStack = p.pairStack[:mark]
+	}()
 
 loop:
 	for p.es.Ok() {
@@ -92,9 +229,10 @@ loop:
 		case p.accept(lexKindRBrace):
 			break loop
 		case p.is(lexKindEof):
-			p.posError(errors.New("unterminated association list"), p.next.pos)
+			p.posError(errors.New("unterminated association list"), p.peek().pos)
 		default:
-			assoc.L = append(assoc.L, p.parsePair())
+			pair := p.parsePair()
+			p.pairStack = append(p.pairStack, pair)
 			if !p.is(lexKindRBrace) && !p.is(lexKindEof) {
 				p.expect(lexKindSpace, "association list pairs must be separated by whitespace")
 			}
@@ -110,7 +248,7 @@ func (p *parser) parsePair() Pair {
 	}
 
 	p.expectP(keyPred, "key in association list pair must be of symbol or interpreted string form")
-	pair := Pair{K: String{pos: p.prev.pos, V: p.prev.s}}
+	pair := Pair{K: String{pos: p.prev.pos, form: p.prev.k.stringForm(), src: p.lexer.source, V: p.str(p.prev.s, p.opts.InternKeys)}}
 
 	if !p.accept(lexKindColon) {
 		p.posError(errors.New("key in association list pair must be immediately followed by colon"), p.prev.pos)
@@ -127,9 +265,9 @@ func (p *parser) parsePair() Pair {
 	case p.is(lexKindLBrace):
 		pair.V = Elem{p.parseAssoc()}
 	case p.is(lexKindRBrace) || p.is(lexKindEof):
-		p.posError(errors.New("unterminated association list pair"), p.next.pos)
+		p.posError(errors.New("unterminated association list pair"), p.peek().pos)
 	default:
-		p.posError(errors.New("expected string, list or association list"), p.next.pos)
+		p.posError(errors.New("expected string, list or association list"), p.peek().pos)
 	}
 
 	// Pair is garbage if an error occurred but it does not really matter
@@ -137,6 +275,24 @@ func (p *parser) parsePair() Pair {
 	return pair
 }
 
+// Count the element just consumed against the element limit.
+func (p *parser) countElem() {
+	if p.elems++; p.opts.MaxElems > 0 && p.elems > p.opts.MaxElems {
+		p.posError(ErrMaxElems, p.prev.pos)
+	}
+}
+
+// Enter the list or association list just consumed, checking the depth limit.
+func (p *parser) enter() {
+	if p.depth++; p.opts.MaxDepth > 0 && p.depth > p.opts.MaxDepth {
+		p.posError(ErrMaxDepth, p.prev.pos)
+	}
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
 // Inject error into the parser's error sink with positional information.
 func (p *parser) posError(err error, pos LexPos) {
 	if err != nil {
@@ -152,33 +308,49 @@ func (p *parser) err() error {
 // Get token from lexer and store any error in the error sink.
 // Not supposed to be used by others than plumbing functions.
 func (p *parser) lex() lexToken {
+	if p.ctx != nil {
+		if err := p.ctx.Err(); err != nil {
+			p.posError(err, p.lexer.pos)
+			return lexToken{k: lexKindEof, pos: p.lexer.pos}
+		}
+	}
 	tok, err := p.lexer.readToken()
 	p.es.Send(err)
 	return tok
 }
 
-// Get the next token from the lexer.
-func (p *parser) consume() {
-	p.prev = p.next
-	if p.next.k != lexKindEof {
+// Get the next token, lexing it if not already done. Tokens are lexed on demand
+// so that a complete element is parsed without reading input beyond it.
+func (p *parser) peek() *lexToken {
+	if !p.lexed {
 		p.next = p.lex()
+		p.lexed = true
+	}
+	return &p.next
+}
+
+// Move on to the next token.
+func (p *parser) consume() {
+	p.prev = *p.peek()
+	if p.prev.k != lexKindEof {
+		p.lexed = false
 	}
 }
 
 // Check if the next token is of the specified kind.
 func (p *parser) is(k lexKind) bool {
-	return p.next.k == k
+	return p.peek().k == k
 }
 
 // Same as is but with a predicate function.
 func (p *parser) isP(pred func(*lexToken) bool) bool {
-	return pred(&p.next)
+	return pred(p.peek())
 }
 
 // Accept the next token if it's of the specified kind.
 // Returns true if the token was accepted.
 func (p *parser) accept(k lexKind) (ok bool) {
-	if p.next.k == k {
+	if p.peek().k == k {
 		ok = true
 		p.consume()
 	}
@@ -189,8 +361,8 @@ func (p *parser) accept(k lexKind) (ok bool) {
 // containing msg into the parser error sink. Returns true if the token was as
 // expected.
 func (p *parser) expect(k lexKind, msg string) (ok bool) {
-	if p.next.k != k {
-		p.posError(errors.New(msg), p.next.pos)
+	if p.peek().k != k {
+		p.posError(errors.New(msg), p.peek().pos)
 		return false
 	}
 	p.consume()
@@ -200,8 +372,8 @@ func (p *parser) expect(k lexKind, msg string) (ok bool) {
 // Same as expect but with a predicate function.
 // Returns true if the token was as expected.
 func (p *parser) expectP(pred func(*lexToken) bool, msg string) (ok bool) {
-	if !pred(&p.next) {
-		p.posError(errors.New(msg), p.next.pos)
+	if !pred(p.peek()) {
+		p.posError(errors.New(msg), p.peek().pos)
 		return false
 	}
 	p.consume()
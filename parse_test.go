@@ -4,9 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"github.com/johan-bolmsjo/errors"
 	"github.com/johan-bolmsjo/saft"
+	"runtime"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+	"unsafe"
 )
 
 func parse(t *testing.T, input string) ([]saft.Elem, error) {
@@ -144,3 +149,298 @@ func TestParse_RootAssocListKeyStringFormError(t *testing.T) {
 	checkElems(t, elems, ``)
 	checkParseError(t, err, "1:1: key in association list pair must be of symbol or interpreted string form")
 }
+
+func TestParseWithOptions_ColumnUnits(t *testing.T) {
+	input := "{\"漢\":\tx :}"
+	_, err := saft.ParseWithOptions(strings.NewReader(input), saft.ParseOptions{Columns: saft.ColumnUTF16})
+	checkParseError(t, err, "1:8: key in association list pair must be of symbol or interpreted string form")
+
+	_, err = saft.ParseWithOptions(strings.NewReader(input), saft.ParseOptions{Columns: saft.ColumnDisplay, TabWidth: 4})
+	checkParseError(t, err, "1:10: key in association list pair must be of symbol or interpreted string form")
+}
+
+func utf16Bytes(s string, bigEndian, bom bool) []byte {
+	var b []byte
+	put := func(u uint16) {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	if bom {
+		put(0xfeff)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		put(u)
+	}
+	return b
+}
+
+func TestParse_Encoding(t *testing.T) {
+	var tbl = []struct {
+		name  string
+		input []byte
+	}{
+		{"UTF-8 BOM", append([]byte{0xef, 0xbb, 0xbf}, "{a:😀}"...)},
+		{"UTF-16BE BOM", utf16Bytes("{a:😀}", true, true)},
+		{"UTF-16LE BOM", utf16Bytes("{a:😀}", false, true)},
+		{"UTF-16BE", utf16Bytes("{a:😀}", true, false)},
+		{"UTF-16LE", utf16Bytes("{a:😀}", false, false)},
+	}
+
+	for _, td := range tbl {
+		elems, err := saft.Parse(bytes.NewReader(td.input))
+		checkError(t, td.name, err, "nil")
+		checkElems(t, elems, `{"a":"😀"  }`)
+
+		// The encoding must not depend on how the input is split into reads.
+		elems, err = saft.Parse(iotest.OneByteReader(bytes.NewReader(td.input)))
+		checkError(t, td.name+" one byte reads", err, "nil")
+		checkElems(t, elems, `{"a":"😀"  }`)
+
+		elems, err = saft.ParseBytes(td.input)
+		checkError(t, td.name+" ParseBytes", err, "nil")
+		checkElems(t, elems, `{"a":"😀"  }`)
+	}
+}
+
+func TestParse_StrictUTF8(t *testing.T) {
+	input := []byte("[a\nb\xffc]")
+
+	elems, err := saft.Parse(bytes.NewReader(input))
+	checkParseError(t, err, "nil")
+	checkElems(t, elems, `["a" "b�c" ]`)
+
+	_, err = saft.ParseWithOptions(bytes.NewReader(input), saft.ParseOptions{StrictUTF8: true})
+	checkParseError(t, err, "2:1: invalid UTF-8 encoding")
+
+	_, err = saft.ParseWithOptions(bytes.NewReader(utf16Bytes("[a", false, true)[:5]), saft.ParseOptions{StrictUTF8: true})
+	checkParseError(t, err, "1:1: invalid UTF-16 encoding")
+}
+
+func TestParse_NormalizeCRLF(t *testing.T) {
+	input := "[" + Q + "a\r\nb\r" + Q + "\r\nc]\r\n"
+
+	elems, err := saft.Parse(strings.NewReader(input))
+	checkParseError(t, err, "nil")
+	checkElems(t, elems, `["a\r\nb\r" "c" ]`)
+
+	elems, err = saft.ParseWithOptions(strings.NewReader(input), saft.ParseOptions{NormalizeCRLF: true})
+	checkParseError(t, err, "nil")
+	checkElems(t, elems, `["a\nb\r" "c" ]`)
+}
+
+func TestParseWithOptions_Limits(t *testing.T) {
+	testData := []struct {
+		input string
+		opts  saft.ParseOptions
+		want  string
+		cause error
+	}{
+		{"[a [b {c:d}]]", saft.ParseOptions{MaxDepth: 3}, "nil", nil},
+		{"[a [b {c:d}]]", saft.ParseOptions{MaxDepth: 2}, "1:6: maximum nesting depth exceeded", saft.ErrMaxDepth},
+		{`abc "abc" ` + Q + "abc" + Q, saft.ParseOptions{MaxStringLen: 3}, "nil", nil},
+		{"a abcd", saft.ParseOptions{MaxStringLen: 3}, "1:2: maximum string length exceeded", saft.ErrMaxStringLen},
+		{`a "abcd"`, saft.ParseOptions{MaxStringLen: 3}, "1:2: maximum string length exceeded", saft.ErrMaxStringLen},
+		{"a " + Q + "abcd" + Q, saft.ParseOptions{MaxStringLen: 3}, "1:2: maximum string length exceeded", saft.ErrMaxStringLen},
+		{"[a {b:c}]", saft.ParseOptions{MaxElems: 4}, "nil", nil},
+		{"[a {b:c}] d", saft.ParseOptions{MaxElems: 4}, "1:10: maximum number of elements exceeded", saft.ErrMaxElems},
+		{"[a b]", saft.ParseOptions{MaxInputBytes: 5}, "nil", nil},
+		{"[a bc]", saft.ParseOptions{MaxInputBytes: 5}, "1:5: maximum input size exceeded", saft.ErrMaxInputBytes},
+		{"a b ", saft.ParseOptions{MaxRootElems: 2}, "nil", nil},
+		{"a b [c]", saft.ParseOptions{MaxRootElems: 2}, "1:4: maximum number of root elements exceeded", saft.ErrMaxRootElems},
+	}
+
+	for _, td := range testData {
+		_, err := saft.ParseWithOptions(strings.NewReader(td.input), td.opts)
+		checkError(t, fmt.Sprintf("ParseWithOptions(%q)", td.input), err, td.want)
+		if td.cause != nil && errors.Cause(err) != td.cause {
+			t.Fatalf("ParseWithOptions(%q) error cause = %v; want %v", td.input, errors.Cause(err), td.cause)
+		}
+	}
+}
+
+func TestParseWithOptions_MaxInputBytesUTF16(t *testing.T) {
+	// The limit applies to the UTF-16 input, which is 12 bytes including the
+	// byte order mark while the transcoded input is 7 bytes.
+	input := utf16Bytes("[a b]", false, true)
+	testData := []struct {
+		max  int64
+		want string
+	}{
+		{12, "nil"},
+		{11, "1:4: maximum input size exceeded"},
+		{7, "1:2: maximum input size exceeded"},
+	}
+	for _, td := range testData {
+		_, err := saft.ParseWithOptions(bytes.NewReader(input), saft.ParseOptions{MaxInputBytes: td.max})
+		checkError(t, fmt.Sprintf("ParseWithOptions() with MaxInputBytes %d", td.max), err, td.want)
+		if td.want != "nil" && errors.Cause(err) != saft.ErrMaxInputBytes {
+			t.Fatalf("ParseWithOptions() error cause = %v; want %v", errors.Cause(err), saft.ErrMaxInputBytes)
+		}
+	}
+
+	// Surrogate pairs are four bytes.
+	input = utf16Bytes("\U0001F600", false, true)
+	_, err := saft.ParseWithOptions(bytes.NewReader(input), saft.ParseOptions{MaxInputBytes: 5})
+	checkError(t, "ParseWithOptions() of surrogate pair", err, "1:0: maximum input size exceeded")
+}
+
+func TestParseBytes(t *testing.T) {
+	testData := []struct {
+		input []byte
+		opts  saft.ParseOptions
+	}{
+		{[]byte("a [b c] {d:e f:\"g\\th\"}"), saft.ParseOptions{}},
+		{[]byte("a\xffb \"c\xffd\""), saft.ParseOptions{}},
+		{[]byte("a\xffb"), saft.ParseOptions{StrictUTF8: true}},
+		{[]byte("\xef\xbb\xbf" + Q + "a\r\nb" + Q + " c"), saft.ParseOptions{NormalizeCRLF: true}},
+		{utf16Bytes("[a b]", false, true), saft.ParseOptions{}},
+		{[]byte("[a {b:c}"), saft.ParseOptions{}},
+		{[]byte("[a abcd]"), saft.ParseOptions{MaxStringLen: 3}},
+	}
+
+	for _, td := range testData {
+		want, wantErr := saft.ParseWithOptions(bytes.NewReader(td.input), td.opts)
+		got, err := saft.ParseBytesWithOptions(td.input, td.opts)
+		checkError(t, fmt.Sprintf("ParseBytesWithOptions(%q)", td.input), err, errorString(wantErr))
+		checkElems(t, got, elemsToString(want))
+	}
+}
+
+func TestParseBytes_NoCopy(t *testing.T) {
+	data := []byte("[abc]")
+	elems, err := saft.ParseBytes(data)
+	checkParseError(t, err, "nil")
+	s, _ := elems[0].Index(0).ExpectString()
+	if unsafe.StringData(s.V) != &data[1] {
+		t.Fatalf("ParseBytes() string %q does not refer to the input", s.V)
+	}
+}
+
+// benchDocument returns a document of about size bytes.
+func benchDocument(size int) []byte {
+	var sb strings.Builder
+	for i := 0; sb.Len() < size; i++ {
+		fmt.Fprintf(&sb, "rule-%d: {\n", i)
+		fmt.Fprintf(&sb, "  match: [host-%d.example.com \"/path/%d\\t\" `raw %d`]\n", i, i, i)
+		fmt.Fprintf(&sb, "  action: {allow: true limit: %d} // Comment\n", i)
+		sb.WriteString("}\n")
+	}
+	return []byte("{\n" + sb.String() + "}\n")
+}
+
+func BenchmarkParse(b *testing.B) {
+	data := benchDocument(1 << 20)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := saft.Parse(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseBytes(b *testing.B) {
+	data := benchDocument(1 << 20)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := saft.ParseBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseWithOptions_InternCompact(t *testing.T) {
+	input := "{a:x b:[x y [z]] c:{a:x}} [w] {a:x}"
+	want, err := saft.Parse(strings.NewReader(input))
+	checkParseError(t, err, "nil")
+
+	for _, opts := range []saft.ParseOptions{
+		{InternKeys: true},
+		{InternStrings: true},
+		{Compact: true},
+		{InternKeys: true, InternStrings: true, Compact: true},
+	} {
+		got, err := saft.ParseWithOptions(strings.NewReader(input), opts)
+		checkParseError(t, err, "nil")
+		checkElems(t, got, elemsToString(want))
+		for i := range got {
+			if !saft.Equal(got[i], want[i], saft.EqualOptions{}) {
+				t.Fatalf("%+v: got %s; want %s", opts, elemsToString(got), elemsToString(want))
+			}
+		}
+
+		// Appending to a list must not affect elements allocated after it.
+		inner, _ := got[0].Lookup(saft.Path{"b", "2"}).ExpectList()
+		inner.Append(saft.ElemOf(saft.NewString("q")))
+		if !saft.Equal(got[0].Get("b"), getTestElem(t, "[x y [z q]]"), saft.EqualOptions{IgnorePos: true, IgnoreForm: true}) {
+			t.Fatalf("%+v: got %s after append", opts, elemsToString(got))
+		}
+
+		first, _ := got[0].ExpectAssoc()
+		last, _ := got[2].ExpectAssoc()
+		keysShared := unsafe.StringData(first.L[0].K.V) == unsafe.StringData(last.L[0].K.V)
+		if keysShared != opts.InternKeys {
+			t.Fatalf("%+v: keys shared = %v", opts, keysShared)
+		}
+		v1, _ := first.L[0].V.ExpectString()
+		v2, _ := last.L[0].V.ExpectString()
+		valuesShared := unsafe.StringData(v1.V) == unsafe.StringData(v2.V)
+		if valuesShared != opts.InternStrings {
+			t.Fatalf("%+v: values shared = %v", opts, valuesShared)
+		}
+	}
+}
+
+// benchKeysDocument returns a document of many pairs with repeated keys.
+func benchKeysDocument(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString("[\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "{name: host-%d port: 80 protocol: tcp enabled: true tags: [web prod]}\n", i)
+	}
+	sb.WriteString("]\n")
+	return []byte(sb.String())
+}
+
+// BenchmarkParseMemory reports the heap retained by parsed documents, in
+// addition to the allocations made while parsing.
+func BenchmarkParseMemory(b *testing.B) {
+	data := benchKeysDocument(10000)
+	for _, bm := range []struct {
+		name string
+		opts saft.ParseOptions
+	}{
+		{"Default", saft.ParseOptions{}},
+		{"InternKeys", saft.ParseOptions{InternKeys: true}},
+		{"InternAll", saft.ParseOptions{InternKeys: true, InternStrings: true}},
+		{"Compact", saft.ParseOptions{Compact: true}},
+		{"InternAllCompact", saft.ParseOptions{InternKeys: true, InternStrings: true, Compact: true}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var retained int64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				elems, err := saft.ParseWithOptions(bytes.NewReader(data), bm.opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				// The heap shrinks if unrelated memory is freed, which
+				// must not wrap around.
+				if d := int64(after.HeapAlloc) - int64(before.HeapAlloc); d > 0 {
+					retained += d
+				}
+				runtime.KeepAlive(elems)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
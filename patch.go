@@ -0,0 +1,527 @@
+package saft
+
+import (
+	"fmt"
+	"github.com/johan-bolmsjo/errors"
+	"strconv"
+	"strings"
+)
+
+// Path addresses an element in a tree of elements. Each segment is either an
+// index into a List or a key in an Assoc, which one is decided by the type of
+// the element the segment is applied to. A key addresses the first pair with
+// that key. The empty path addresses the root element.
+type Path []string
+
+// ParsePath parses a path in dotted notation such as "servers.web.port".
+// The empty string is parsed as the empty path.
+func ParsePath(s string) Path {
+	if s == "" {
+		return nil
+	}
+	return Path(strings.Split(s, "."))
+}
+
+// String implements the fmt.Stringer interface.
+// The path is formatted in the dotted notation accepted by ParsePath.
+func (path Path) String() string {
+	return strings.Join(path, ".")
+}
+
+// dotted returns true if the path can be represented in dotted notation.
+func (path Path) dotted() bool {
+	for _, seg := range path {
+		if seg == "" || strings.Contains(seg, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// PatchOpKind is the kind of operation performed by a patch operation.
+type PatchOpKind int8
+
+const (
+	PatchAdd     PatchOpKind = iota // Insert into a list or append a pair to an association list
+	PatchRemove                     // Remove an element from a list or a pair from an association list
+	PatchReplace                    // Replace an existing element
+	PatchMove                       // Remove an element and add it at another path
+	PatchTest                       // Test that an element is equal to a value
+)
+
+var patchOpKindItoa = map[PatchOpKind]string{
+	PatchAdd:     "add",
+	PatchRemove:  "remove",
+	PatchReplace: "replace",
+	PatchMove:    "move",
+	PatchTest:    "test",
+}
+
+// String implements the fmt.Stringer interface.
+func (kind PatchOpKind) String() string {
+	if s, ok := patchOpKindItoa[kind]; ok {
+		return s
+	}
+	return "?"
+}
+
+// PatchOp is a single patch operation.
+//
+// The last segment of the path of an add operation into a list is the index
+// the value is inserted at. The index may be equal to the length of the list or
+// "-" to append to the list. Adding to an association list always appends a new
+// pair since pairs with identical keys are allowed.
+type PatchOp struct {
+	Kind  PatchOpKind
+	Path  Path // Target of the operation
+	From  Path // Source of move operations
+	Value Elem // Value of add, replace and test operations
+	pos   LexPos
+}
+
+// Pos returns the lexed position of a patch operation parsed by ParsePatch.
+func (op *PatchOp) Pos() LexPos {
+	return op.pos
+}
+
+// errorf returns an error prefixed by the operation. The position is omitted
+// for operations that were not parsed, such as those created by Diff.
+func (op *PatchOp) errorf(format string, a ...interface{}) error {
+	msg := fmt.Sprintf("%s %s: %s", op.Kind, op.Path, fmt.Sprintf(format, a...))
+	if !op.pos.IsValid() {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("%s: %s", &op.pos, msg)
+}
+
+// Patch is a sequence of patch operations that are applied in order.
+//
+// A patch is represented in Saft as a list of association lists, one per
+// operation, with the keys op, path, from and value. Paths are written either
+// in dotted notation or as a list of segments:
+//
+//	[
+//	    {op:replace path:servers.web.port value:9090}
+//	    {op:remove path:rules.1}
+//	    {op:add path:[hosts "example.com"] value:{port:80}}
+//	]
+type Patch []PatchOp
+
+// ParsePatch parses a patch from its Saft representation.
+// Returns the patch or an error containing positional information.
+func ParsePatch(e Elem) (Patch, error) {
+	list, err := e.ExpectList()
+	if err != nil {
+		return nil, err
+	}
+
+	patch := make(Patch, 0, len(list.L))
+	for _, opElem := range list.L {
+		op, err := parsePatchOp(opElem)
+		if err != nil {
+			return nil, err
+		}
+		patch = append(patch, op)
+	}
+	return patch, nil
+}
+
+func parsePatchOp(e Elem) (op PatchOp, err error) {
+	assoc, err := e.ExpectAssoc()
+	if err != nil {
+		return op, err
+	}
+	op.pos = assoc.pos
+
+	var haveKind, havePath, haveFrom, haveValue bool
+	for i := range assoc.L {
+		pair := &assoc.L[i]
+		switch pair.K.V {
+		case "op":
+			var s *String
+			if s, err = pair.V.ExpectString(); err != nil {
+				return op, err
+			}
+			if op.Kind, err = parsePatchOpKind(s); err != nil {
+				return op, err
+			}
+			haveKind = true
+		case "path":
+			if op.Path, err = parsePatchPath(pair.V); err != nil {
+				return op, err
+			}
+			havePath = true
+		case "from":
+			if op.From, err = parsePatchPath(pair.V); err != nil {
+				return op, err
+			}
+			haveFrom = true
+		case "value":
+			op.Value = pair.V
+			haveValue = true
+		default:
+			return op, fmt.Errorf("%s: unknown patch operation key %q", &pair.K.pos, pair.K.V)
+		}
+	}
+
+	if !haveKind {
+		return op, fmt.Errorf("%s: patch operation is missing op", &op.pos)
+	}
+	if !havePath {
+		return op, fmt.Errorf("%s: patch operation is missing path", &op.pos)
+	}
+	switch op.Kind {
+	case PatchAdd, PatchReplace, PatchTest:
+		if !haveValue {
+			return op, fmt.Errorf("%s: %s operation is missing value", &op.pos, op.Kind)
+		}
+	case PatchMove:
+		if !haveFrom {
+			return op, fmt.Errorf("%s: %s operation is missing from", &op.pos, op.Kind)
+		}
+	}
+	return op, nil
+}
+
+func parsePatchOpKind(s *String) (PatchOpKind, error) {
+	for kind, name := range patchOpKindItoa {
+		if s.V == name {
+			return kind, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: unknown patch operation: %s", s.where(), s.V)
+}
+
+func parsePatchPath(e Elem) (Path, error) {
+	if s, ok := e.IsString(); ok {
+		return ParsePath(s.V), nil
+	}
+	list, ok := e.IsList()
+	if !ok {
+		return nil, fmt.Errorf("%s: expected path as string or list, found %s", e.where(), e.Kind())
+	}
+	path := make(Path, 0, len(list.L))
+	for _, segElem := range list.L {
+		seg, err := segElem.ExpectString()
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, seg.V)
+	}
+	return path, nil
+}
+
+// Elem returns the Saft representation of the patch as accepted by ParsePatch.
+func (patch Patch) Elem() Elem {
+	list := &List{L: make([]Elem, 0, len(patch))}
+	for i := range patch {
+		op := &patch[i]
+		assoc := &Assoc{pos: op.pos}
+		assoc.L = append(assoc.L, Pair{K: String{V: "op"}, V: Elem{&String{V: op.Kind.String()}}})
+		assoc.L = append(assoc.L, Pair{K: String{V: "path"}, V: pathElem(op.Path)})
+		if op.Kind == PatchMove {
+			assoc.L = append(assoc.L, Pair{K: String{V: "from"}, V: pathElem(op.From)})
+		}
+		if !op.Value.IsZero() {
+			assoc.L = append(assoc.L, Pair{K: String{V: "value"}, V: op.Value})
+		}
+		list.L = append(list.L, Elem{assoc})
+	}
+	return Elem{list}
+}
+
+func pathElem(path Path) Elem {
+	if path.dotted() {
+		return Elem{&String{V: path.String()}}
+	}
+	list := &List{L: make([]Elem, 0, len(path))}
+	for _, seg := range path {
+		list.L = append(list.L, Elem{&String{V: seg}})
+	}
+	return Elem{list}
+}
+
+// Values are compared by content only when testing and diffing.
+var patchEqualOptions = EqualOptions{IgnorePos: true, IgnoreForm: true}
+
+// Apply patch to a copy of e. The element e is left unmodified.
+// Returns the patched element or an error containing positional information of
+// the failing operation.
+func Apply(e Elem, patch Patch) (Elem, error) {
+	root := e.Copy()
+	for i := range patch {
+		if err := applyPatchOp(&root, &patch[i]); err != nil {
+			return Elem{}, err
+		}
+	}
+	return root, nil
+}
+
+func applyPatchOp(root *Elem, op *PatchOp) error {
+	switch op.Kind {
+	case PatchAdd:
+		return patchAdd(root, op, op.Path, op.Value.Copy())
+	case PatchRemove:
+		_, err := patchRemove(root, op, op.Path)
+		return err
+	case PatchReplace:
+		target, err := resolvePath(root, op, op.Path)
+		if err != nil {
+			return err
+		}
+		*target = op.Value.Copy()
+	case PatchMove:
+		if len(op.From) < len(op.Path) && pathHasPrefix(op.Path, op.From) {
+			return op.errorf("can not move %s into itself", op.From)
+		}
+		value, err := patchRemove(root, op, op.From)
+		if err != nil {
+			return err
+		}
+		return patchAdd(root, op, op.Path, value)
+	case PatchTest:
+		target, err := resolvePath(root, op, op.Path)
+		if err != nil {
+			return err
+		}
+		if !Equal(*target, op.Value, patchEqualOptions) {
+			return op.errorf("test failed")
+		}
+	default:
+		return op.errorf("unknown patch operation")
+	}
+	return nil
+}
+
+func pathHasPrefix(path, prefix Path) bool {
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func patchAdd(root *Elem, op *PatchOp, path Path, value Elem) error {
+	if len(path) == 0 {
+		*root = value
+		return nil
+	}
+	parent, err := resolvePath(root, op, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	seg := path[len(path)-1]
+
+	if list, ok := parent.IsList(); ok {
+		i := len(list.L)
+		if seg != "-" {
+			if i, err = listIndex(op, list, seg, true); err != nil {
+				return err
+			}
+		}
+		list.Insert(i, value)
+	} else if assoc, ok := parent.IsAssoc(); ok {
+		assoc.L.Add(seg, value)
+	} else {
+		return op.errorf("can not add to %s", parent.Kind())
+	}
+	return nil
+}
+
+func patchRemove(root *Elem, op *PatchOp, path Path) (Elem, error) {
+	if len(path) == 0 {
+		return Elem{}, op.errorf("can not remove root element")
+	}
+	parent, err := resolvePath(root, op, path[:len(path)-1])
+	if err != nil {
+		return Elem{}, err
+	}
+	seg := path[len(path)-1]
+
+	var value Elem
+	if list, ok := parent.IsList(); ok {
+		i, err := listIndex(op, list, seg, false)
+		if err != nil {
+			return Elem{}, err
+		}
+		value = list.L[i]
+		list.Delete(i)
+	} else if assoc, ok := parent.IsAssoc(); ok {
+		i, err := assocIndex(op, assoc, seg)
+		if err != nil {
+			return Elem{}, err
+		}
+		value = assoc.L[i].V
+		assoc.L.deleteIndex(i)
+	} else {
+		return Elem{}, op.errorf("can not remove from %s", parent.Kind())
+	}
+	return value, nil
+}
+
+// resolvePath returns a pointer to the element addressed by path.
+func resolvePath(root *Elem, op *PatchOp, path Path) (*Elem, error) {
+	e := root
+	for _, seg := range path {
+		if list, ok := e.IsList(); ok {
+			i, err := listIndex(op, list, seg, false)
+			if err != nil {
+				return nil, err
+			}
+			e = &list.L[i]
+		} else if assoc, ok := e.IsAssoc(); ok {
+			i, err := assocIndex(op, assoc, seg)
+			if err != nil {
+				return nil, err
+			}
+			e = &assoc.L[i].V
+		} else {
+			return nil, op.errorf("can not index %s with %q", e.Kind(), seg)
+		}
+	}
+	return e, nil
+}
+
+// listIndex parses seg as an index into list. The index may be one past the
+// end of the list if inclusive is set.
+func listIndex(op *PatchOp, list *List, seg string, inclusive bool) (int, error) {
+	i, err := strconv.Atoi(seg)
+	if err != nil {
+		return 0, op.errorf("invalid list index %q", seg)
+	}
+	n := len(list.L)
+	if inclusive {
+		n++
+	}
+	if i < 0 || i >= n {
+		return 0, op.errorf("list index %d out of range", i)
+	}
+	return i, nil
+}
+
+func assocIndex(op *PatchOp, assoc *Assoc, key string) (int, error) {
+	if i := assoc.L.index(key); i >= 0 {
+		return i, nil
+	}
+	return 0, op.errorf("no such key %q", key)
+}
+
+// Diff returns a patch that transforms a into b when applied to a.
+//
+// Association lists are patched pair by pair when both have unique keys and
+// the pairs they have in common appear in the same order. They are replaced as
+// a whole otherwise, since pairs can only be added at the end.
+func Diff(a, b Elem) Patch {
+	var patch Patch
+	diffElem(&patch, nil, a, b)
+	return patch
+}
+
+func diffElem(patch *Patch, path Path, a, b Elem) {
+	replace := func() {
+		*patch = append(*patch, PatchOp{Kind: PatchReplace, Path: clonePath(path), Value: b.Copy()})
+	}
+
+	switch x := a.any.(type) {
+	case *String:
+		if y, ok := b.IsString(); !ok || x.V != y.V {
+			replace()
+		}
+	case *List:
+		if y, ok := b.IsList(); ok {
+			diffList(patch, path, x, y)
+		} else {
+			replace()
+		}
+	case *Assoc:
+		if y, ok := b.IsAssoc(); ok && diffableAssocs(x, y) {
+			diffAssoc(patch, path, x, y)
+		} else if !ok || !Equal(a, b, patchEqualOptions) {
+			replace()
+		}
+	default:
+		if !b.IsZero() {
+			replace()
+		}
+	}
+}
+
+func diffList(patch *Patch, path Path, a, b *List) {
+	n := len(a.L)
+	if len(b.L) < n {
+		n = len(b.L)
+	}
+	for i := 0; i < n; i++ {
+		diffElem(patch, append(path, strconv.Itoa(i)), a.L[i], b.L[i])
+	}
+	for i := n; i < len(b.L); i++ {
+		*patch = append(*patch, PatchOp{Kind: PatchAdd, Path: clonePath(append(path, strconv.Itoa(i))), Value: b.L[i].Copy()})
+	}
+	// Remove from the end so that indices stay valid.
+	for i := len(a.L) - 1; i >= n; i-- {
+		*patch = append(*patch, PatchOp{Kind: PatchRemove, Path: clonePath(append(path, strconv.Itoa(i)))})
+	}
+}
+
+// diffableAssocs returns true if the pairs of a can be patched into the pairs of
+// b without replacing the association list.
+func diffableAssocs(a, b *Assoc) bool {
+	aKeys, ok := uniqueKeys(a)
+	if !ok {
+		return false
+	}
+	if _, ok = uniqueKeys(b); !ok {
+		return false
+	}
+
+	// Pairs in common must be in the same order and new pairs must come last.
+	var added bool
+	next := 0
+	for i := range b.L {
+		j, common := aKeys[b.L[i].K.V]
+		if !common {
+			added = true
+			continue
+		}
+		if added || j < next {
+			return false
+		}
+		next = j
+	}
+	return true
+}
+
+func uniqueKeys(assoc *Assoc) (map[string]int, bool) {
+	keys := make(map[string]int, len(assoc.L))
+	for i := range assoc.L {
+		if _, dup := keys[assoc.L[i].K.V]; dup {
+			return nil, false
+		}
+		keys[assoc.L[i].K.V] = i
+	}
+	return keys, true
+}
+
+func diffAssoc(patch *Patch, path Path, a, b *Assoc) {
+	bKeys, _ := uniqueKeys(b)
+	for i := range a.L {
+		key := a.L[i].K.V
+		if j, ok := bKeys[key]; ok {
+			diffElem(patch, append(path, key), a.L[i].V, b.L[j].V)
+		} else {
+			*patch = append(*patch, PatchOp{Kind: PatchRemove, Path: clonePath(append(path, key))})
+		}
+	}
+	aKeys, _ := uniqueKeys(a)
+	for i := range b.L {
+		key := b.L[i].K.V
+		if _, ok := aKeys[key]; !ok {
+			*patch = append(*patch, PatchOp{Kind: PatchAdd, Path: clonePath(append(path, key)), Value: b.L[i].V.Copy()})
+		}
+	}
+}
+
+func clonePath(path Path) Path {
+	return append(Path(nil), path...)
+}
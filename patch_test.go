@@ -0,0 +1,90 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"testing"
+)
+
+func getPatch(t *testing.T, s string) saft.Patch {
+	patch, err := saft.ParsePatch(getTestElem(t, s))
+	checkError(t, "saft.ParsePatch()", err, "nil")
+	return patch
+}
+
+func TestPatch_Apply(t *testing.T) {
+	doc := getTestElem(t, `{servers:{web:{port:80}} rules:[a b c] hosts:[]}`)
+	patch := getPatch(t, `[
+{op:replace path:servers.web.port value:9090}
+{op:remove path:rules.1}
+{op:add path:rules.- value:d}
+{op:add path:[servers "db.local"] value:{port:5432}}
+{op:move from:rules.0 path:hosts.0}
+{op:test path:hosts value:[a]}
+]`)
+
+	got, err := saft.Apply(doc, patch)
+	checkError(t, "saft.Apply()", err, "nil")
+	checkElems(t, []saft.Elem{got}, `{"servers":{"web":{"port":"9090"  } "db.local":{"port":"5432"  } } "rules":["c" "d" ] "hosts":["a" ] }`)
+
+	// The patched element must be a copy.
+	checkElems(t, []saft.Elem{doc}, `{"servers":{"web":{"port":"80"  } } "rules":["a" "b" "c" ] "hosts":[] }`)
+}
+
+func TestPatch_ApplyError(t *testing.T) {
+	doc := getTestElem(t, `{rules:[a b] name:x}`)
+
+	var tbl = []struct{ patch, error string }{
+		{`[{op:remove path:rules.2}]`, "1:1: remove rules.2: list index 2 out of range"},
+		{`[{op:replace path:nope value:x}]`, `1:1: replace nope: no such key "nope"`},
+		{`[{op:add path:name.x value:y}]`, "1:1: add name.x: can not add to string"},
+		{`[{op:test path:name value:y}]`, "1:1: test name: test failed"},
+		{`[{op:move from:rules path:rules.0}]`, "1:1: move rules.0: can not move rules into itself"},
+	}
+
+	for _, td := range tbl {
+		_, err := saft.Apply(doc, getPatch(t, td.patch))
+		checkError(t, "saft.Apply()", err, td.error)
+	}
+
+	// Operations built in code have no position.
+	_, err := saft.Apply(doc, saft.Patch{{Kind: saft.PatchRemove, Path: saft.ParsePath("rules.2")}})
+	checkError(t, "saft.Apply()", err, "remove rules.2: list index 2 out of range")
+}
+
+func TestPatch_ParseError(t *testing.T) {
+	var tbl = []struct{ patch, error string }{
+		{`{}`, "1:0: expected list, found association list"},
+		{`[{op:copy path:a}]`, "1:5: unknown patch operation: copy"},
+		{`[{op:add path:a}]`, "1:1: add operation is missing value"},
+		{`[{path:a}]`, "1:1: patch operation is missing op"},
+		{`[{op:remove path:a x:y}]`, `1:19: unknown patch operation key "x"`},
+	}
+
+	for _, td := range tbl {
+		_, err := saft.ParsePatch(getTestElem(t, td.patch))
+		checkError(t, "saft.ParsePatch()", err, td.error)
+	}
+}
+
+func TestPatch_Diff(t *testing.T) {
+	var tbl = []struct{ a, b, patch string }{
+		{`x`, `x`, `[]`},
+		{`x`, `y`, `[{"op":"replace"  "path":""  "value":"y"  }]`},
+		{`[a b c]`, `[a x]`, `[{"op":"replace"  "path":"1"  "value":"x"  }{"op":"remove"  "path":"2"  }]`},
+		{`{a:1 b:2}`, `{a:1 c:3}`, `[{"op":"remove"  "path":"b"  }{"op":"add"  "path":"c"  "value":"3"  }]`},
+		{`{a:1 b:2}`, `{b:2 a:1}`, `[{"op":"replace"  "path":""  "value":{"b":"2"  "a":"1"  } }]`},
+		{`{a:{"x.y":[]}}`, `{a:{"x.y":[z]}}`, `[{"op":"add"  "path":["a" "x.y" "0" ] "value":"z"  }]`},
+	}
+
+	for _, td := range tbl {
+		a, b := getTestElem(t, td.a), getTestElem(t, td.b)
+		patch := saft.Diff(a, b)
+		checkElems(t, []saft.Elem{patch.Elem()}, td.patch)
+
+		got, err := saft.Apply(a, patch)
+		checkError(t, "saft.Apply()", err, "nil")
+		if elemsToString([]saft.Elem{got}) != elemsToString([]saft.Elem{b}) {
+			t.Fatalf("saft.Apply(%s, saft.Diff(%s, %s)) = %s", td.a, td.a, td.b, elemsToString([]saft.Elem{got}))
+		}
+	}
+}
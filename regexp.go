@@ -0,0 +1,61 @@
+package saft
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// Parse string as a regular expression as described by regexp.Compile.
+// Returns the compiled expression or an error containing positional
+// information. The position refers to the part of the expression the error is
+// about when possible, rather than to the start of the string.
+func (s *String) Regexp() (*regexp.Regexp, error) {
+	re, err := regexp.Compile(s.V)
+	if err != nil {
+		pos := s.where()
+		if serr, ok := err.(*syntax.Error); ok {
+			if i := strings.Index(s.V, serr.Expr); i >= 0 && serr.Expr != "" {
+				pos = s.whereAt(i)
+			}
+		}
+		return nil, fmt.Errorf("%s: %s", pos, err)
+	}
+	return re, nil
+}
+
+// RegexpRaw is like Regexp but also requires the string to be written in raw
+// form, which avoids the double escaping needed in interpreted strings.
+func (s *String) RegexpRaw() (*regexp.Regexp, error) {
+	if err := s.ExpectForm(FormRaw); err != nil {
+		return nil, err
+	}
+	return s.Regexp()
+}
+
+// Glob is a compiled shell file name pattern with the syntax of path.Match.
+type Glob struct {
+	pattern string
+}
+
+// Match reports whether name matches the pattern.
+func (g *Glob) Match(name string) bool {
+	ok, _ := path.Match(g.pattern, name)
+	return ok
+}
+
+// String implements the fmt.Stringer interface.
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Parse string as a shell file name pattern as described by path.Match.
+// Returns the compiled pattern or an error containing positional information.
+func (s *String) Glob() (*Glob, error) {
+	if _, err := path.Match(s.V, ""); err != nil {
+		return nil, fmt.Errorf("%s: %s: %s", s.where(), err, s.V)
+	}
+	return &Glob{pattern: s.V}, nil
+}
@@ -0,0 +1,73 @@
+package saft_test
+
+import (
+	"fmt"
+	"github.com/johan-bolmsjo/saft"
+	"strings"
+	"testing"
+)
+
+func TestString_Regexp(t *testing.T) {
+	s, _ := getTestElem(t, Q+`^\d+$`+Q).ExpectString()
+	re, err := s.Regexp()
+	checkError(t, "s.Regexp()", err, "nil")
+	if !re.MatchString("42") {
+		t.Fatalf("regexp %s does not match %q", re, "42")
+	}
+}
+
+func TestString_RegexpErrorPos(t *testing.T) {
+	var tbl = []struct{ input, error string }{
+		{Q + `x[z-a]` + Q, "1:3: error parsing regexp: invalid character class range: `z-a`"},
+		{`  "x\\d[z-a]"`, "1:8: error parsing regexp: invalid character class range: `z-a`"},
+		{"  " + Q + "abc\n\tx[z-a]" + Q, "2:10: error parsing regexp: invalid character class range: `z-a`"},
+		{`a(b`, "1:0: error parsing regexp: missing closing ): `a(b`"},
+	}
+
+	for _, td := range tbl {
+		s, _ := getTestElem(t, td.input).ExpectString()
+		_, err := s.Regexp()
+		checkError(t, td.input+".Regexp()", err, td.error)
+	}
+}
+
+func TestString_RegexpErrorPosColumns(t *testing.T) {
+	input := "\t" + Q + "\U0001F600\tx[z-a]" + Q
+	for _, td := range []struct {
+		opts  saft.ParseOptions
+		error string
+	}{
+		{saft.ParseOptions{}, "1:18: error parsing regexp: invalid character class range: `z-a`"},
+		{saft.ParseOptions{TabWidth: 4}, "1:10: error parsing regexp: invalid character class range: `z-a`"},
+		{saft.ParseOptions{Columns: saft.ColumnUTF16}, "1:7: error parsing regexp: invalid character class range: `z-a`"},
+	} {
+		elems, err := saft.ParseWithOptions(strings.NewReader(input), td.opts)
+		checkParseError(t, err, "nil")
+		s, _ := elems[0].ExpectString()
+		_, err = s.Regexp()
+		checkError(t, fmt.Sprintf("s.Regexp() with %+v", td.opts), err, td.error)
+	}
+}
+
+func TestString_RegexpRaw(t *testing.T) {
+	s, _ := getTestElem(t, `"a"`).ExpectString()
+	_, err := s.RegexpRaw()
+	checkError(t, "s.RegexpRaw()", err, "1:0: expected raw string, found interpreted string")
+
+	s, _ = getTestElem(t, Q+`a`+Q).ExpectString()
+	_, err = s.RegexpRaw()
+	checkError(t, "s.RegexpRaw()", err, "nil")
+}
+
+func TestString_Glob(t *testing.T) {
+	s, _ := getTestElem(t, `*.saft`).ExpectString()
+	g, err := s.Glob()
+	checkError(t, "s.Glob()", err, "nil")
+	if !g.Match("a.saft") || g.Match("a.json") {
+		t.Fatalf("glob %s returned unexpected result", g)
+	}
+
+	s, _ = getTestElem(t, Q+`a[`+Q).ExpectString()
+	_, err = s.Glob()
+	checkError(t, "s.Glob()", err, "1:0: syntax error in pattern: a[")
+}
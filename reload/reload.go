@@ -0,0 +1,157 @@
+/*
+Package reload reloads configuration from Saft files while a program is
+running.
+
+A Watcher parses a set of files, decodes them one at a time into a
+configuration value using a caller provided function and publishes the value as
+an immutable snapshot.
+The files are reloaded periodically by Run or on demand by Reload, such as on
+SIGHUP. A new snapshot is only published if the content of the files changed
+and decoded successfully; otherwise the previous snapshot is kept.
+*/
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"github.com/johan-bolmsjo/saft"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DecodeFunc decodes and validates a parsed file into the configuration value
+// cfg. It's called for each file in file name order with the same cfg, starting
+// from the zero value. Errors should contain positional information; the file
+// name is added by the watcher.
+type DecodeFunc[T any] func(cfg *T, file saft.File) error
+
+// Options control how a Watcher loads files.
+type Options struct {
+	Loader   saft.Loader   // Loader used to read and parse files
+	Interval time.Duration // Polling interval of Run; one minute if zero
+	OnError  func(error)   // Called with errors of failed reloads unless nil
+	OnReload func()        // Called after a new snapshot is published unless nil; may call Reload
+}
+
+// Watcher publishes snapshots of configuration of type T.
+//
+// Published snapshots are shared by all readers and must not be modified.
+// A watcher is safe for concurrent use.
+type Watcher[T any] struct {
+	names   []string
+	decode  DecodeFunc[T]
+	opts    Options
+	current atomic.Pointer[T]
+
+	mu       sync.Mutex // Serializes reloads
+	hash     [sha256.Size]byte
+	failHash [sha256.Size]byte // Hash of content that failed to decode
+	failErr  error             // Error of decoding failHash content or nil
+}
+
+// New returns a watcher of the named files, which are loaded once before
+// returning. An error is returned if the initial load fails.
+func New[T any](names []string, decode DecodeFunc[T], opts Options) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		names:  append([]string(nil), names...),
+		decode: decode,
+		opts:   opts,
+	}
+	if w.opts.Interval <= 0 {
+		w.opts.Interval = time.Minute
+	}
+	if _, _, err := w.reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Load returns the current snapshot.
+func (w *Watcher[T]) Load() *T {
+	return w.current.Load()
+}
+
+// Reload reloads the files and publishes a new snapshot if their content
+// changed. Returns whether a new snapshot was published. On error the current
+// snapshot is kept and the error is also passed to the OnError callback.
+//
+// Content that failed to decode is not decoded again until it changes; the
+// error of the failed attempt is returned instead.
+func (w *Watcher[T]) Reload(ctx context.Context) (changed bool, err error) {
+	if changed, _, err = w.reload(ctx); err != nil && w.opts.OnError != nil {
+		w.opts.OnError(err)
+	}
+	return
+}
+
+// reload reloads the files and calls the OnReload callback if a new snapshot
+// was published. The callback is called without holding the lock so that it
+// may call Reload. Also returns whether the error is that of a previous attempt.
+func (w *Watcher[T]) reload(ctx context.Context) (changed, repeated bool, err error) {
+	if changed, repeated, err = w.load(ctx); changed && w.opts.OnReload != nil {
+		w.opts.OnReload()
+	}
+	return
+}
+
+func (w *Watcher[T]) load(ctx context.Context) (bool, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	files, err := w.opts.Loader.Load(ctx, w.names...)
+	if err != nil {
+		return false, false, err
+	}
+
+	hash := filesHash(files)
+	if w.current.Load() != nil && hash == w.hash {
+		return false, false, nil
+	}
+	if w.failErr != nil && hash == w.failHash {
+		return false, true, w.failErr
+	}
+
+	v := new(T)
+	for _, f := range files {
+		if err := w.decode(v, f); err != nil {
+			w.failHash, w.failErr = hash, &saft.FileError{Name: f.Name, Err: err}
+			return false, false, w.failErr
+		}
+	}
+	w.hash, w.failErr = hash, nil
+	w.current.Store(v)
+	return true, false, nil
+}
+
+// Run reloads the files periodically until ctx is done. Errors are passed to
+// the OnError callback, except for errors due to ctx being done and repeated
+// errors of content that failed to decode before.
+func (w *Watcher[T]) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, repeated, err := w.reload(ctx); err != nil && !repeated && ctx.Err() == nil && w.opts.OnError != nil {
+				w.opts.OnError(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// filesHash hashes the names and content of files. Formatting changes that do
+// not change the content of files do not change the hash, see saft.Hash.
+func filesHash(files []saft.File) (sum [sha256.Size]byte) {
+	h := sha256.New()
+	for _, f := range files {
+		contentHash := saft.Hash(f.Elems...)
+		h.Write([]byte(f.Name))
+		h.Write([]byte{0})
+		h.Write(contentHash[:])
+	}
+	h.Sum(sum[:0])
+	return
+}
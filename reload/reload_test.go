@@ -0,0 +1,239 @@
+package reload_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/johan-bolmsjo/saft"
+	"github.com/johan-bolmsjo/saft/reload"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type config struct {
+	Port int64
+}
+
+func decodeConfig(cfg *config, file saft.File) error {
+	for _, e := range file.Elems {
+		s, err := e.Get("port").ExpectString()
+		if err != nil {
+			return err
+		}
+		if cfg.Port, err = s.IntInRange(1, 65535); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkError(t *testing.T, ctx string, err error, want string) {
+	t.Helper()
+	got := "nil"
+	if err != nil {
+		got = err.Error()
+	}
+	if got != want {
+		t.Fatalf("%s error = %q; want %q", ctx, got, want)
+	}
+}
+
+func checkPort(t *testing.T, w *reload.Watcher[config], want int64) {
+	t.Helper()
+	if got := w.Load().Port; got != want {
+		t.Fatalf("Load() got port %d; want %d", got, want)
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	fsys := fstest.MapFS{"app.saft": {Data: []byte("{port: 80}")}}
+	var errs []error
+	reloads := 0
+	w, err := reload.New([]string{"app.saft"}, decodeConfig, reload.Options{
+		Loader:   saft.Loader{FS: fsys},
+		OnError:  func(err error) { errs = append(errs, err) },
+		OnReload: func() { reloads++ },
+	})
+	checkError(t, "New()", err, "nil")
+	checkPort(t, w, 80)
+	first := w.Load()
+
+	// Formatting changes do not publish a new snapshot.
+	fsys["app.saft"] = &fstest.MapFile{Data: []byte("{\n  port: \"80\"\n}")}
+	changed, err := w.Reload(context.Background())
+	checkError(t, "Reload()", err, "nil")
+	if changed || w.Load() != first {
+		t.Fatalf("Reload() published a snapshot of unchanged content")
+	}
+
+	fsys["app.saft"] = &fstest.MapFile{Data: []byte("{port: 8080}")}
+	changed, err = w.Reload(context.Background())
+	checkError(t, "Reload()", err, "nil")
+	if !changed {
+		t.Fatalf("Reload() did not publish a snapshot of changed content")
+	}
+	checkPort(t, w, 8080)
+
+	// The previous snapshot is kept on errors.
+	fsys["app.saft"] = &fstest.MapFile{Data: []byte("{port: 0}")}
+	_, err = w.Reload(context.Background())
+	checkError(t, "Reload()", err, "app.saft: 1:7: value 0 out of range [1, 65535]")
+	fsys["app.saft"] = &fstest.MapFile{Data: []byte("{port: [}")}
+	_, err = w.Reload(context.Background())
+	checkError(t, "Reload()", err, "app.saft: 1:8: expected string, list or association list")
+	checkPort(t, w, 8080)
+
+	if len(errs) != 2 || reloads != 2 {
+		t.Fatalf("got %d errors and %d reloads; want 2 and 2", len(errs), reloads)
+	}
+}
+
+func TestNew_Error(t *testing.T) {
+	fsys := fstest.MapFS{"app.saft": {Data: []byte("\n  {}")}}
+	_, err := reload.New([]string{"app.saft"}, decodeConfig, reload.Options{Loader: saft.Loader{FS: fsys}})
+	checkError(t, "New()", err, `app.saft: 2:2: missing value for key "port"`)
+}
+
+func TestWatcher_Run(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.saft")
+	// Files are replaced atomically so that partially written files are
+	// never loaded.
+	write := func(port int) {
+		tmp := name + ".tmp"
+		if err := os.WriteFile(tmp, []byte(fmt.Sprintf("{port: %d}", port)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1)
+
+	reloaded := make(chan struct{}, 1)
+	w, err := reload.New([]string{name}, decodeConfig, reload.Options{
+		Interval: time.Millisecond,
+		OnError:  func(err error) { t.Errorf("OnError(%v)", err) },
+		OnReload: func() {
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		},
+	})
+	checkError(t, "New()", err, "nil")
+	<-reloaded // Initial load
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.Run(ctx)
+	}()
+
+	// Readers run concurrently with reloads.
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if w.Load().Port == 0 {
+					t.Errorf("Load() got incomplete snapshot")
+				}
+			}
+		}()
+	}
+
+	write(2)
+	select {
+	case <-reloaded:
+	case <-time.After(10 * time.Second):
+		t.Error("timeout waiting for reload")
+	}
+	cancel()
+	wg.Wait()
+	checkPort(t, w, 2)
+}
+
+func TestWatcher_ReloadFromOnReload(t *testing.T) {
+	fsys := fstest.MapFS{"app.saft": {Data: []byte("{port: 80}")}}
+	var w *reload.Watcher[config]
+	var err error
+	calls := 0
+	w, err = reload.New([]string{"app.saft"}, decodeConfig, reload.Options{
+		Loader: saft.Loader{FS: fsys},
+		OnReload: func() {
+			if calls++; w != nil {
+				// Does not deadlock and finds the content unchanged.
+				changed, err := w.Reload(context.Background())
+				if changed || err != nil {
+					t.Errorf("Reload() = (%v, %v); want (false, nil)", changed, err)
+				}
+			}
+		},
+	})
+	checkError(t, "New()", err, "nil")
+
+	fsys["app.saft"] = &fstest.MapFile{Data: []byte("{port: 8080}")}
+	_, err = w.Reload(context.Background())
+	checkError(t, "Reload()", err, "nil")
+	checkPort(t, w, 8080)
+	if calls != 2 {
+		t.Fatalf("got %d OnReload calls; want 2", calls)
+	}
+}
+
+// notifyFS notifies opened of opened files if a receiver is waiting.
+type notifyFS struct {
+	files  fstest.MapFS
+	opened chan struct{}
+}
+
+func (fsys notifyFS) Open(name string) (fs.File, error) {
+	select {
+	case fsys.opened <- struct{}{}:
+	default:
+	}
+	return fsys.files.Open(name)
+}
+
+func TestWatcher_RunRepeatedError(t *testing.T) {
+	fsys := notifyFS{fstest.MapFS{"app.saft": {Data: []byte("{port: 80}")}}, make(chan struct{})}
+	errs := make(chan error, 16)
+	w, err := reload.New([]string{"app.saft"}, decodeConfig, reload.Options{
+		Loader:   saft.Loader{FS: fsys},
+		Interval: time.Millisecond,
+		OnError:  func(err error) { errs <- err },
+	})
+	checkError(t, "New()", err, "nil")
+
+	fsys.files["app.saft"] = &fstest.MapFile{Data: []byte("{port: 0}")}
+	_, err = w.Reload(context.Background())
+	checkError(t, "Reload()", err, "app.saft: 1:7: value 0 out of range [1, 65535]")
+	<-errs
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(ctx)
+	}()
+	// Content that failed to decode is not reported again while unchanged.
+	for i := 0; i < 3; i++ {
+		<-fsys.opened
+	}
+	cancel()
+	<-done
+	if len(errs) != 0 {
+		t.Fatalf("Run() reported %d errors of unchanged content; want 0", len(errs))
+	}
+
+	// The error is still returned by Reload.
+	_, err = w.Reload(context.Background())
+	checkError(t, "Reload()", err, "app.saft: 1:7: value 0 out of range [1, 65535]")
+	checkPort(t, w, 80)
+}
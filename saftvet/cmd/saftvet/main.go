@@ -0,0 +1,15 @@
+// Saftvet checks struct tags used by saft.Decode, see package saftvet.
+//
+// Usage:
+//
+//	go vet -vettool=$(which saftvet) ./...
+package main
+
+import (
+	"github.com/johan-bolmsjo/saft/saftvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(saftvet.Analyzer)
+}
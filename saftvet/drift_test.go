@@ -0,0 +1,131 @@
+package saftvet
+
+import (
+	"flag"
+	"github.com/johan-bolmsjo/saft"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The analyzer mirrors how saft.Decode maps fields to keys and which types it
+// decodes from strings. These tests compare the two so that they don't drift
+// apart.
+
+// typeCheck returns the types of the variables declared by src, which may
+// import packages of the standard library.
+func typeCheck(t *testing.T, src string) map[string]types.Type {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "drift.go", "package drift\n"+src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("drift", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := make(map[string]types.Type)
+	for _, name := range pkg.Scope().Names() {
+		vars[name] = pkg.Scope().Lookup(name).Type()
+	}
+	return vars
+}
+
+func TestStructKeys(t *testing.T) {
+	type keys struct {
+		Port     int
+		ÅngPort  int
+		Host     string `saft:"host-name"`
+		Timeout  int    `saft:",default=5"`
+		Skipped  int    `saft:"-"`
+		internal int
+	}
+	vars := typeCheck(t, `var keys struct {
+		Port     int
+		ÅngPort  int
+		Host     string `+"`saft:\"host-name\"`"+`
+		Timeout  int    `+"`saft:\",default=5\"`"+`
+		Skipped  int    `+"`saft:\"-\"`"+`
+		internal int
+	}`)
+
+	var got []string
+	for _, f := range structKeys(vars["keys"].(*types.Struct)) {
+		got = append(got, f.key)
+	}
+	sort.Strings(got)
+
+	// Overlay settings are named by the keys of the decoder.
+	var overlay saft.Overlay
+	overlay.AddStruct(&keys{})
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	overlay.RegisterFlags(fs)
+	var want []string
+	fs.VisitAll(func(f *flag.Flag) { want = append(want, f.Name) })
+
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("structKeys() = %q; saft.Decode uses %q", got, want)
+	}
+}
+
+func TestDecodedFromString(t *testing.T) {
+	vars := typeCheck(t, `import ("net/netip"; "time")
+	type named int
+	var (
+		vString   string
+		vBool     bool
+		vInt8     int8
+		vUint64   uint64
+		vFloat32  float32
+		vNamed    named
+		vDuration time.Duration
+		vTime     time.Time
+		vAddr     netip.Addr
+		vSlice    []string
+		vMap      map[string]int
+		vStruct   struct{ A int }
+		vPointer  *int
+	)`)
+	type named int
+	values := map[string]any{
+		"vString":   "",
+		"vBool":     false,
+		"vInt8":     int8(0),
+		"vUint64":   uint64(0),
+		"vFloat32":  float32(0),
+		"vNamed":    named(0),
+		"vDuration": time.Duration(0),
+		"vTime":     time.Time{},
+		"vAddr":     netip.Addr{},
+		"vSlice":    []string{},
+		"vMap":      map[string]int{},
+		"vStruct":   struct{ A int }{},
+		"vPointer":  (*int)(nil),
+	}
+	if len(vars) != len(values)+1 { // The named type is a declaration too
+		t.Fatalf("got %d declarations; want %d", len(vars), len(values)+1)
+	}
+
+	for name, v := range values {
+		// The decoder expects strings for types decoded from strings.
+		rv := reflect.New(reflect.TypeOf(v))
+		err := saft.Decode(saft.ElemOf(saft.NewList()), rv.Interface())
+		want := err != nil && strings.Contains(err.Error(), "expected string")
+		if reflect.TypeOf(v).Kind() == reflect.Pointer {
+			want = false // Followed by the decoder, not decoded from strings as such
+		}
+		if got := decodedFromString(vars[name]); got != want {
+			t.Errorf("decodedFromString(%s) = %v; saft.Decode gives %v (%v)", vars[name], got, want, err)
+		}
+	}
+}
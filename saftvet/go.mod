@@ -0,0 +1,18 @@
+module github.com/johan-bolmsjo/saft/saftvet
+
+go 1.22.0
+
+require (
+	github.com/johan-bolmsjo/saft v0.0.0
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	github.com/johan-bolmsjo/errors v1.0.0 // indirect
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
+
+// Built against the saft module of the same checkout, see the package
+// documentation. The required version is a placeholder.
+replace github.com/johan-bolmsjo/saft => ../
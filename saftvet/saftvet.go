@@ -0,0 +1,377 @@
+/*
+Package saftvet defines an analyzer that checks struct tags used by saft.Decode.
+
+Typos in tags and fields of types the decoder cannot handle are otherwise only
+discovered when a document is decoded at runtime. The analyzer checks structs
+having at least one field with a "saft" or "saftdoc" tag for:
+
+  - Unknown tag options, such as `saft:"port,defualt=80"`.
+  - Tags on unexported fields, which are ignored by the decoder.
+  - Fields mapped to the same key.
+  - Fields of types the decoder cannot handle, such as channels and maps
+    without string keys.
+  - Default values that do not parse for the type of the field.
+
+Default values of named types other than time.Duration and time.Time decoded
+from strings are not checked, since they may be enumerations registered at
+runtime or implement encoding.TextUnmarshaler.
+
+The analyzer is run by the saftvet command, which can be used with go vet. The
+saftvet module is built against the saft module of the same checkout, which
+go install with a version does not support, so it's installed from a checkout:
+
+	git clone https://github.com/johan-bolmsjo/saft
+	cd saft/saftvet && go install ./cmd/saftvet
+	go vet -vettool=$(which saftvet) ./...
+*/
+package saftvet
+
+import (
+	"fmt"
+	"github.com/johan-bolmsjo/saft"
+	"go/ast"
+	"go/types"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Analyzer checks struct tags used by saft.Decode.
+var Analyzer = &analysis.Analyzer{
+	Name:     "saftvet",
+	Doc:      "check struct tags used by saft.Decode",
+	URL:      "https://pkg.go.dev/github.com/johan-bolmsjo/saft/saftvet",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+const saftPath = "github.com/johan-bolmsjo/saft"
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		checkStruct(pass, n.(*ast.StructType))
+	})
+	return nil, nil
+}
+
+// keyedField is a field of a struct mapped to a key, see saft.Decode.
+type keyedField struct {
+	v    *types.Var
+	key  string
+	opts string // Tag options following the key
+}
+
+// structKeys returns the fields of st mapped to keys the way saft.Decode does,
+// see structFields in the saft package. The two are compared by
+// TestStructKeys.
+func structKeys(st *types.Struct) []keyedField {
+	var fields []keyedField
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i)).Get("saft")
+		if !v.Exported() || tag == "-" {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		if key == "" {
+			key = lowerFirst(v.Name())
+		}
+		fields = append(fields, keyedField{v: v, key: key, opts: opts})
+	}
+	return fields
+}
+
+func lowerFirst(s string) string {
+	for i, r := range s {
+		return string(unicode.ToLower(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
+
+// parseOptions returns the default value of tag options and any unknown
+// options. The default option extends to the end of the tag.
+func parseOptions(opts string) (def string, hasDef bool, unknown []string) {
+	for opts != "" {
+		if strings.HasPrefix(opts, "default=") {
+			return opts[len("default="):], true, unknown
+		}
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		unknown = append(unknown, opt)
+	}
+	return "", false, unknown
+}
+
+func checkStruct(pass *analysis.Pass, node *ast.StructType) {
+	tagged := false
+	fieldNodes := make(map[*types.Var]*ast.Field)
+	for _, f := range node.Fields.List {
+		if f.Tag != nil {
+			value, _ := strconv.Unquote(f.Tag.Value)
+			tag := reflect.StructTag(value)
+			if _, ok := tag.Lookup("saft"); ok {
+				tagged = true
+			} else if _, ok := tag.Lookup("saftdoc"); ok {
+				tagged = true
+			}
+		}
+		names := f.Names
+		if names == nil {
+			names = []*ast.Ident{embeddedIdent(f.Type)}
+		}
+		for _, name := range names {
+			if v, ok := pass.TypesInfo.Defs[name].(*types.Var); ok && name != nil {
+				fieldNodes[v] = f
+			}
+		}
+	}
+	if !tagged {
+		return
+	}
+	st, ok := pass.TypesInfo.TypeOf(node).(*types.Struct)
+	if !ok {
+		return
+	}
+
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		if tag, ok := reflect.StructTag(st.Tag(i)).Lookup("saft"); ok && !v.Exported() && tag != "-" {
+			pass.Reportf(v.Pos(), "saft tag on unexported field %s is ignored", v.Name())
+		}
+	}
+
+	keys := make(map[string]*types.Var)
+	for _, f := range structKeys(st) {
+		pos := f.v.Pos()
+		if n := fieldNodes[f.v]; n != nil && n.Tag != nil {
+			pos = n.Tag.Pos()
+		}
+
+		def, hasDef, unknown := parseOptions(f.opts)
+		for _, opt := range unknown {
+			pass.Reportf(pos, "unknown option %q in saft tag of field %s", opt, f.v.Name())
+		}
+		if prev := keys[f.key]; prev != nil {
+			pass.Reportf(pos, "field %s has the same key %q as field %s", f.v.Name(), f.key, prev.Name())
+		} else {
+			keys[f.key] = f.v
+		}
+
+		if t := unsupportedType(f.v.Type(), []types.Type{st}); t != nil {
+			pass.Reportf(f.v.Pos(), "saft cannot decode field %s of type %s", f.v.Name(), t)
+			continue
+		}
+		if hasDef {
+			origin := fmt.Sprintf("default value %q of field %s", def, f.v.Name())
+			if err := checkDefault(pass, f.v.Type(), def, origin); err != nil {
+				pass.Reportf(pos, "invalid %v", err)
+			}
+		}
+	}
+}
+
+// embeddedIdent returns the identifier of the type name of an embedded field.
+func embeddedIdent(x ast.Expr) *ast.Ident {
+	for {
+		switch t := x.(type) {
+		case *ast.Ident:
+			return t
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.SelectorExpr:
+			return t.Sel
+		case *ast.IndexExpr:
+			x = t.X
+		case *ast.IndexListExpr:
+			x = t.X
+		default:
+			return nil
+		}
+	}
+}
+
+func isNamed(t types.Type, pkgPath, name string) bool {
+	n, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := n.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkgPath && obj.Name() == name
+}
+
+func isElem(t types.Type) bool { return isNamed(t, saftPath, "Elem") }
+
+func implementsTextUnmarshaler(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), false, nil, "UnmarshalText")
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+// decodedFromString returns true if values of type t are decoded from strings,
+// see decodedFromString in the saft package. Enumerations registered at
+// runtime are not known. The two are compared by TestDecodedFromString.
+func decodedFromString(t types.Type) bool {
+	if isNamed(t, "time", "Duration") || isNamed(t, "time", "Time") || implementsTextUnmarshaler(t) {
+		return true
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&(types.IsString|types.IsBoolean|types.IsInteger|types.IsFloat) != 0 &&
+		b.Info()&types.IsUntyped == 0
+}
+
+// unsupportedType returns the type of t, or a type t is composed of, that
+// saft.Decode cannot decode into. Returns nil if t is supported. Structs in
+// seen are not checked again.
+func unsupportedType(t types.Type, seen []types.Type) types.Type {
+	if isElem(t) || decodedFromString(t) {
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return unsupportedType(u.Elem(), seen)
+	case *types.Slice:
+		return unsupportedType(u.Elem(), seen)
+	case *types.Map:
+		if b, ok := u.Key().Underlying().(*types.Basic); !ok || b.Info()&types.IsString == 0 {
+			return t
+		}
+		return unsupportedType(u.Elem(), seen)
+	case *types.Struct:
+		for _, s := range seen {
+			if types.Identical(s, u) {
+				return nil // Recursive type
+			}
+		}
+		seen = append(seen, u)
+		for _, f := range structKeys(u) {
+			if bad := unsupportedType(f.v.Type(), seen); bad != nil {
+				return bad
+			}
+		}
+		return nil
+	}
+	return t
+}
+
+// checkDefault checks that the default value def decodes into type t. Errors
+// refer to origin, see saft.String.Origin.
+func checkDefault(pass *analysis.Pass, t types.Type, def, origin string) error {
+	for {
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok || decodedFromString(t) {
+			break
+		}
+		t = p.Elem()
+	}
+	if decodedFromString(t) {
+		return checkString(pass, t, saft.NewStringOrigin(def, origin))
+	}
+	elems, err := saft.Parse(strings.NewReader(def))
+	if err == nil && len(elems) != 1 {
+		err = fmt.Errorf("expected one element, found %d", len(elems))
+	}
+	if err == nil {
+		err = checkElem(pass, t, elems[0])
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", origin, err)
+	}
+	return nil
+}
+
+// checkElem checks that e decodes into type t.
+func checkElem(pass *analysis.Pass, t types.Type, e saft.Elem) error {
+	if isElem(t) {
+		return nil
+	}
+	if decodedFromString(t) {
+		s, err := e.ExpectString()
+		if err != nil {
+			return err
+		}
+		return checkString(pass, t, s)
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return checkElem(pass, u.Elem(), e)
+	case *types.Slice:
+		list, err := e.ExpectList()
+		if err != nil {
+			return err
+		}
+		for _, x := range list.L {
+			if err := checkElem(pass, u.Elem(), x); err != nil {
+				return err
+			}
+		}
+	case *types.Map:
+		assoc, err := e.ExpectAssoc()
+		if err != nil {
+			return err
+		}
+		for _, pair := range assoc.L {
+			if err := checkElem(pass, u.Elem(), pair.V); err != nil {
+				return err
+			}
+		}
+	case *types.Struct:
+		assoc, err := e.ExpectAssoc()
+		if err != nil {
+			return err
+		}
+		fields := structKeys(u)
+	pairLoop:
+		for _, pair := range assoc.L {
+			for _, f := range fields {
+				if f.key == pair.K.V {
+					if err := checkElem(pass, f.v.Type(), pair.V); err != nil {
+						return err
+					}
+					continue pairLoop
+				}
+			}
+			pos := pair.K.Pos()
+			return fmt.Errorf("%s: unknown key %q", &pos, pair.K.V)
+		}
+	}
+	return nil
+}
+
+// checkString checks that s decodes into type t. Values of named types other
+// than time.Duration and time.Time are not checked.
+func checkString(pass *analysis.Pass, t types.Type, s *saft.String) error {
+	switch {
+	case isNamed(t, "time", "Duration"):
+		_, err := s.Duration()
+		return err
+	case isNamed(t, "time", "Time"):
+		_, err := s.Time("")
+		return err
+	}
+	b, ok := types.Unalias(t).(*types.Basic)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	bits := 8 * pass.TypesSizes.Sizeof(t)
+	switch {
+	case b.Info()&types.IsBoolean != 0:
+		_, err = s.Bool()
+	case b.Info()&types.IsUnsigned != 0:
+		_, err = s.UintInRange(0, ^uint64(0)>>(64-bits))
+	case b.Info()&types.IsInteger != 0:
+		_, err = s.IntInRange(-1<<(bits-1), 1<<(bits-1)-1)
+	case b.Kind() == types.Float32:
+		_, err = s.Float32()
+	case b.Kind() == types.Float64:
+		_, err = s.Float64()
+	}
+	return err
+}
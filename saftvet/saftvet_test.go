@@ -0,0 +1,11 @@
+package saftvet_test
+
+import (
+	"github.com/johan-bolmsjo/saft/saftvet"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"testing"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), saftvet.Analyzer, "a")
+}
@@ -0,0 +1,38 @@
+package a
+
+import (
+	"net/netip"
+	"time"
+)
+
+type Config struct {
+	Host    string         `saft:"host,default=localhost" saftdoc:"Host to listen on"`
+	Port    uint16         `saft:"port,default=70000"`  // want `invalid default value "70000" of field Port: value 70000 out of range \[0, 65535\]`
+	Timeout time.Duration  `saft:"timeout,defualt=5s"`  // want `unknown option "defualt=5s" in saft tag of field Timeout`
+	Retry   time.Duration  `saft:"retry,default=often"` // want `invalid default value "often" of field Retry`
+	Addr    netip.Addr     `saft:"addr,default=whatever"`
+	Tags    []string       `saft:"tags,default=[a b]"`
+	Limits  map[string]int `saft:"limits,default={a: x}"`            // want `invalid default value "{a: x}" of field Limits: 1:4: strconv.ParseInt`
+	Server  Server         `saft:"server,default={port: 1 name: x}"` // want `invalid default value .* 1:9: unknown key "name"`
+	Alias   string         `saft:"host"`                             // want `field Alias has the same key "host" as field Host`
+	Events  chan int       // want `saft cannot decode field Events of type chan int`
+	ByID    map[int]Server // want `saft cannot decode field ByID of type map\[int\]a.Server`
+	Nested  []Nested       // want `saft cannot decode field Nested of type func\(\)`
+	Self    *Config
+	secret  string   `saft:"secret"` // want `saft tag on unexported field secret is ignored`
+	Skipped chan int `saft:"-"`
+}
+
+type Server struct {
+	Port int `saft:"port"`
+}
+
+type Nested struct {
+	F func()
+}
+
+// Structs without saft tags are not checked.
+type Other struct {
+	C chan int
+	D string `json:"d"`
+}
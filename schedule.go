@@ -0,0 +1,191 @@
+package saft
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron like schedule of minutes.
+//
+// A schedule has five space separated fields: minute (0-59), hour (0-23), day
+// of month (1-31), month (1-12 or jan-dec) and day of week (0-7 or sun-sat,
+// where both 0 and 7 is Sunday). Each field is a comma separated list of "*",
+// single values or ranges "a-b", where "*" and ranges may be followed by a step
+// "/n". A time matches the schedule if all fields match, except that it's
+// enough for one of the day fields to match if both are restricted.
+//
+// The shorthands @yearly, @annually, @monthly, @weekly, @daily, @midnight and
+// @hourly are also recognized.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // Bit sets of matching values
+	domStar, dowStar              bool   // Day fields unrestricted?
+}
+
+var scheduleShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+type scheduleField struct {
+	name     string
+	min, max int
+	names    []string // Symbolic names of values starting at min
+}
+
+var scheduleFields = []scheduleField{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, monthNames},
+	{"day of week", 0, 7, weekdayNames},
+}
+
+// Parse string as a cron like schedule. See Schedule for the syntax.
+// Returns the parsed schedule or an error containing positional information.
+func (s *String) Schedule() (*Schedule, error) {
+	sched, err := parseSchedule(s.V)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid schedule %q: %s", s.where(), s.V, err)
+	}
+	return sched, nil
+}
+
+func parseSchedule(spec string) (*Schedule, error) {
+	if expanded, ok := scheduleShorthands[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		spec = expanded
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(scheduleFields) {
+		return nil, fmt.Errorf("expected %d fields, found %d", len(scheduleFields), len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		if bits[i], err = scheduleFields[i].parse(field); err != nil {
+			return nil, err
+		}
+	}
+
+	sched := &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}
+	// Sunday may be written as 7.
+	if sched.dow&(1<<7) != 0 {
+		sched.dow |= 1
+	}
+	return sched, nil
+}
+
+func (f *scheduleField) parse(field string) (bits uint64, err error) {
+	for _, item := range strings.Split(field, ",") {
+		rangeSpec, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			rangeSpec = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, item[i+1:])
+			}
+		}
+
+		var lo, hi int
+		if rangeSpec == "*" {
+			lo, hi = f.min, f.max
+		} else if i := strings.IndexByte(rangeSpec, '-'); i >= 0 {
+			if lo, err = f.value(rangeSpec[:i]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(rangeSpec[i+1:]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: invalid range %q", f.name, rangeSpec)
+			}
+		} else {
+			if lo, err = f.value(rangeSpec); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if step != 1 {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f *scheduleField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: value %d out of range %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// scheduleSearchYears limits how far into the future Next searches for a
+// matching time, since some schedules such as February 30 never match.
+const scheduleSearchYears = 5
+
+// Next returns the first time matching the schedule strictly after t, in the
+// location of t. The zero time is returned if there is no such time.
+func (sched *Schedule) Next(t time.Time) time.Time {
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(scheduleSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if sched.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !sched.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if sched.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if sched.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (sched *Schedule) dayMatches(t time.Time) bool {
+	domMatch := sched.dom&(1<<uint(t.Day())) != 0
+	dowMatch := sched.dow&(1<<uint(t.Weekday())) != 0
+	if sched.domStar || sched.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
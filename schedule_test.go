@@ -0,0 +1,49 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"testing"
+	"time"
+)
+
+func TestString_Schedule(t *testing.T) {
+	// Friday
+	from := time.Date(2024, 3, 1, 10, 17, 42, 0, time.UTC)
+
+	var tbl = []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17 * * mon-fri", time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 8 * * sat,sun", time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)},
+		{"0 0 13 * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, td := range tbl {
+		sched, err := (&saft.String{V: td.spec}).Schedule()
+		checkError(t, td.spec+".Schedule()", err, "nil")
+		if got := sched.Next(from); !got.Equal(td.want) {
+			t.Fatalf("%q.Next(%v) = %v; want %v", td.spec, from, got, td.want)
+		}
+	}
+}
+
+func TestString_ScheduleError(t *testing.T) {
+	var tbl = []struct{ spec, error string }{
+		{"* * * *", `0:0: invalid schedule "* * * *": expected 5 fields, found 4`},
+		{"60 * * * *", `0:0: invalid schedule "60 * * * *": minute: value 60 out of range 0-59`},
+		{"* * * foo *", `0:0: invalid schedule "* * * foo *": month: invalid value "foo"`},
+		{"*/0 * * * *", `0:0: invalid schedule "*/0 * * * *": minute: invalid step "0"`},
+		{"* 5-1 * * *", `0:0: invalid schedule "* 5-1 * * *": hour: invalid range "5-1"`},
+	}
+
+	for _, td := range tbl {
+		_, err := (&saft.String{V: td.spec}).Schedule()
+		checkError(t, td.spec+".Schedule()", err, td.error)
+	}
+}
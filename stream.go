@@ -0,0 +1,137 @@
+package saft
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ParseContext is like Parse but gives up when ctx is done, also while blocked
+// reading from reader. The returned error is then the context's error wrapped
+// with the position parsing stopped at; use errors.Cause to identify it.
+//
+// A read blocked when ctx is done is stopped by setting a read deadline in the
+// past if reader has a SetReadDeadline method, such as net.Conn, or otherwise by
+// closing reader if it implements io.Closer.
+func ParseContext(ctx context.Context, reader io.Reader) ([]Elem, error) {
+	return ParseContextWithOptions(ctx, reader, ParseOptions{})
+}
+
+// ParseContextWithOptions is like ParseContext but controlled by options.
+func ParseContextWithOptions(ctx context.Context, reader io.Reader, opts ParseOptions) ([]Elem, error) {
+	cr := &ctxReader{r: reader, ctx: ctx}
+	parser := newParser(cr, &opts)
+	parser.ctx = ctx
+	if elems := parser.parseRoot(); parser.err() == nil {
+		return elems, nil
+	}
+	return nil, parser.err()
+}
+
+// Decoder parses a stream of root elements one at a time, such as documents
+// received over a network connection. Input is only read as far as needed to
+// parse each element.
+type Decoder struct {
+	cr     *ctxReader
+	opts   ParseOptions
+	parser *parser // Created by the first call to Decode
+}
+
+// NewDecoder returns a decoder parsing root elements from reader.
+func NewDecoder(reader io.Reader, opts ParseOptions) *Decoder {
+	return &Decoder{cr: &ctxReader{r: reader}, opts: opts}
+}
+
+// Decode parses the next root element. It gives up when ctx is done, also while
+// blocked reading input, in which case the error is the context's error wrapped
+// with the position decoding stopped at. A blocked read is stopped as by
+// ParseContext.
+// Returns io.EOF at the end of the input. Any other error is permanent and
+// returned by all subsequent calls.
+func (d *Decoder) Decode(ctx context.Context) (Elem, error) {
+	d.cr.ctx = ctx
+	if d.parser == nil {
+		d.parser = newParser(d.cr, &d.opts)
+	}
+	d.parser.ctx = ctx
+	if lex := d.parser.lexer; len(lex.source.crs) > 0 {
+		// Returned strings keep referring to the previous source
+		lex.source = &source{cols: lex.source.cols}
+	}
+
+	elem, ok := d.parser.parseRootElem()
+	if err := d.parser.err(); err != nil {
+		return Elem{}, err
+	}
+	if !ok {
+		return Elem{}, io.EOF
+	}
+	return elem, nil
+}
+
+// ctxReader is a reader that can be abandoned while blocked by cancelling ctx.
+//
+// Reads from the underlying reader are made in a separate goroutine. The
+// goroutine of an abandoned read remains blocked until the underlying reader
+// returns, unless the read can be stopped, see stop.
+type ctxReader struct {
+	r       io.Reader
+	ctx     context.Context
+	pending chan ctxReadResult // Outstanding read or nil
+	buf     []byte             // Data read but not yet returned
+	err     error              // Error to return once buf is drained
+}
+
+type ctxReadResult struct {
+	b   []byte
+	err error
+}
+
+// Read implements the io.Reader interface.
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if len(cr.buf) == 0 && cr.err == nil {
+		if err := cr.ctx.Err(); err != nil {
+			return 0, err
+		}
+		if cr.pending == nil {
+			ch := make(chan ctxReadResult, 1)
+			b := make([]byte, len(p))
+			go func() {
+				n, err := cr.r.Read(b)
+				ch <- ctxReadResult{b[:n], err}
+			}()
+			cr.pending = ch
+		}
+		select {
+		case res := <-cr.pending:
+			cr.pending = nil
+			cr.buf, cr.err = res.b, res.err
+		case <-cr.ctx.Done():
+			cr.stop()
+			return 0, cr.ctx.Err()
+		}
+	}
+
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	if len(cr.buf) == 0 && cr.err != nil {
+		err := cr.err
+		cr.err = nil
+		return n, err
+	}
+	return n, nil
+}
+
+// stop unblocks the outstanding read of an abandoned reader by setting a read
+// deadline in the past or, if that's not supported, by closing the underlying
+// reader.
+func (cr *ctxReader) stop() {
+	if r, ok := cr.r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		if r.SetReadDeadline(time.Unix(1, 0)) == nil {
+			return
+		}
+	}
+	if c, ok := cr.r.(io.Closer); ok {
+		c.Close()
+	}
+}
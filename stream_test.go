@@ -0,0 +1,151 @@
+package saft_test
+
+import (
+	"context"
+	"github.com/johan-bolmsjo/errors"
+	"github.com/johan-bolmsjo/saft"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContext(t *testing.T) {
+	elems, err := saft.ParseContext(context.Background(), strings.NewReader("a [b c] {d:e}"))
+	checkParseError(t, err, "nil")
+	checkElems(t, elems, `"a"  ["b" "c" ] {"d":"e"  }`)
+}
+
+// blockingReader returns chunks of input sent to it and blocks when there are
+// none, until closed.
+type blockingReader struct {
+	chunks  chan string   // Input; closing the channel results in io.EOF
+	blocked chan struct{} // Receives when a read blocks for lack of input
+	closed  chan struct{} // Closed by Close
+	buf     string
+}
+
+func newBlockingReader(chunks ...string) *blockingReader {
+	r := &blockingReader{
+		chunks:  make(chan string, 8),
+		blocked: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	for _, s := range chunks {
+		r.chunks <- s
+	}
+	return r
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	if r.buf == "" {
+		var ok bool
+		select {
+		case r.buf, ok = <-r.chunks:
+		default:
+			select {
+			case r.buf, ok = <-r.chunks:
+			case <-r.closed:
+				return 0, io.ErrClosedPipe
+			case r.blocked <- struct{}{}:
+				select {
+				case r.buf, ok = <-r.chunks:
+				case <-r.closed:
+					return 0, io.ErrClosedPipe
+				}
+			}
+		}
+		if !ok {
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *blockingReader) Close() error {
+	close(r.closed)
+	return nil
+}
+
+// cancelWhenBlocked cancels ctx once a read from r blocks.
+func cancelWhenBlocked(r *blockingReader) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-r.blocked
+		cancel()
+	}()
+	return ctx
+}
+
+func TestParseContext_Cancel(t *testing.T) {
+	r := newBlockingReader("[a\nb ")
+	_, err := saft.ParseContext(cancelWhenBlocked(r), r)
+	checkParseError(t, err, "2:2: context canceled")
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("ParseContext() error cause = %v; want %v", errors.Cause(err), context.Canceled)
+	}
+
+	// The blocked read is stopped by closing the reader.
+	select {
+	case <-r.closed:
+	default:
+		t.Fatalf("ParseContext() did not close the reader")
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	r := newBlockingReader()
+	dec := saft.NewDecoder(r, saft.ParseOptions{})
+	decode := func(want string) {
+		t.Helper()
+		elem, err := dec.Decode(context.Background())
+		checkError(t, "Decode()", err, "nil")
+		checkElems(t, []saft.Elem{elem}, want)
+	}
+
+	// Elements are returned without waiting for more input.
+	r.chunks <- "[a b]"
+	decode(`["a" "b" ]`)
+	r.chunks <- "\n{c:d}"
+	decode(`{"c":"d"  }`)
+
+	r.chunks <- " [e"
+	_, err := dec.Decode(cancelWhenBlocked(r))
+	checkError(t, "Decode()", err, "2:8: context canceled")
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("Decode() error cause = %v; want %v", errors.Cause(err), context.Canceled)
+	}
+
+	// Errors are permanent.
+	_, err = dec.Decode(context.Background())
+	checkError(t, "Decode()", err, "2:8: context canceled")
+}
+
+func TestDecoder_ShortElement(t *testing.T) {
+	// The first element is shorter than a byte order mark.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte("{}"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	elem, err := saft.NewDecoder(pr, saft.ParseOptions{}).Decode(ctx)
+	checkError(t, "Decode()", err, "nil")
+	checkElems(t, []saft.Elem{elem}, `{}`)
+}
+
+func TestDecoder_EOF(t *testing.T) {
+	dec := saft.NewDecoder(strings.NewReader("a b\n"), saft.ParseOptions{})
+	for _, want := range []string{`"a"`, `"b"`} {
+		elem, err := dec.Decode(context.Background())
+		checkError(t, "Decode()", err, "nil")
+		checkElems(t, []saft.Elem{elem}, want)
+	}
+	_, err := dec.Decode(context.Background())
+	checkError(t, "Decode()", err, io.EOF.Error())
+	_, err = dec.Decode(context.Background())
+	checkError(t, "Decode()", err, io.EOF.Error())
+}
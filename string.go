@@ -5,12 +5,50 @@ import (
 	"github.com/johan-bolmsjo/errors"
 	"net"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // String is surprisingly a string.
 type String struct {
-	pos LexPos
-	V   string // String value
+	pos  LexPos
+	form StringForm
+	src  *source // Shared by the strings of a parse or nil
+	V    string  // String value
+}
+
+// StringForm is the syntax form a string was written in.
+type StringForm int8
+
+const (
+	FormUnspecified StringForm = iota // Not parsed, e.g. constructed by NewString
+	FormSymbol                        // Unquoted
+	FormInterpreted                   // Quoted using "
+	FormRaw                           // Quoted using `
+)
+
+var stringFormItoa = map[StringForm]string{
+	FormUnspecified: "unspecified",
+	FormSymbol:      "symbol",
+	FormInterpreted: "interpreted",
+	FormRaw:         "raw",
+}
+
+// String implements the fmt.Stringer interface.
+func (form StringForm) String() string {
+	return stringFormItoa[form]
+}
+
+// NewString returns a new string with the specified value.
+func NewString(v string) *String {
+	return &String{V: v}
+}
+
+// NewStringOrigin returns a new string with the specified value and origin,
+// see Origin. Errors about the string refer to its origin rather than to a
+// position, such as "env APP_SERVER_PORT: ...".
+func NewStringOrigin(v, origin string) *String {
+	return &String{src: &source{origin: origin}, V: v}
 }
 
 // Pos returns positional information useful for context dependent error reporting.
@@ -18,18 +56,85 @@ func (s *String) Pos() LexPos {
 	return s.pos
 }
 
-func (s *String) elemType() elemType {
-	return elemTypeString
+// Origin describes where the string came from if it was not parsed from a
+// document, such as "env APP_SERVER_PORT" for a value overlaid from the
+// environment. Returns an empty string for parsed strings.
+func (s *String) Origin() string {
+	return s.src.originOf()
+}
+
+// where returns the position of the string for error messages, which is its
+// origin if it has one.
+func (s *String) where() string {
+	return s.src.where(&s.pos)
+}
+
+// whereAt is like where but returns the position of the byte at index i of
+// the string value, see PosAt.
+func (s *String) whereAt(i int) string {
+	if origin := s.Origin(); origin != "" {
+		return origin
+	}
+	pos := s.PosAt(i)
+	return pos.String()
+}
+
+// Form returns the syntax form the string was written in.
+func (s *String) Form() StringForm {
+	return s.form
+}
+
+func (s *String) kind() Kind {
+	return KindString
+}
+
+// ExpectForm expects that the string is written in the specified syntax form.
+// Returns an error containing positional information if it's not.
+func (s *String) ExpectForm(form StringForm) error {
+	if s.form != form {
+		return fmt.Errorf("%s: expected %s string, found %s string", s.where(), form, s.form)
+	}
+	return nil
+}
+
+// PosAt returns the lexed position of the byte at index i of the string
+// value, which must be at most the length of the value. Useful for reporting
+// errors found within strings. The opening quote and escape sequences of the string form are
+// accounted for. A literal tab in an interpreted string is assumed to have been
+// written as an escape sequence since the two can't be told apart. Columns are
+// counted using the column semantics the string was parsed with and CRs
+// dropped from raw strings by ParseOptions.NormalizeCRLF are accounted for.
+func (s *String) PosAt(i int) LexPos {
+	src := s.src
+	if src == nil || src.cols.tabWidth == 0 { // Not parsed
+		src = &defaultSource
+	}
+	cols := src.cols
+	pos := s.pos
+	if s.form == FormInterpreted || s.form == FormRaw {
+		cols.advance(&pos, '"', 1)
+	}
+	for _, r := range s.V[:i] {
+		switch {
+		case s.form == FormInterpreted && (r == '\\' || r == '"' || r == '\n' || r == '\r' || r == '\t'):
+			cols.advance(&pos, '\\', 1)
+			r = 'x' // Any character occupying one column
+		case s.form == FormRaw && r == '\n' && src.droppedCR(pos.Offset):
+			cols.advance(&pos, '\r', 1)
+		}
+		cols.advance(&pos, r, utf8.RuneLen(r))
+	}
+	return pos
 }
 
 func wrapStrconvIntError(err error, s *String) error {
 	// strconv error contain source string
-	return errors.Wrap(err, s.pos.String())
+	return errors.Wrap(err, s.where())
 }
 
 func wrapStrconvFloatError(err error, s *String) error {
 	// strconv error contain source string
-	return errors.Wrap(err, s.pos.String())
+	return errors.Wrap(err, s.where())
 }
 
 // Parse string as a boolean.
@@ -37,7 +142,7 @@ func wrapStrconvFloatError(err error, s *String) error {
 func (s *String) Bool() (v bool, err error) {
 	if v, err = strconv.ParseBool(s.V); err != nil {
 		// strconv error contain source string
-		err = errors.Wrap(err, s.pos.String())
+		err = errors.Wrap(err, s.where())
 	}
 	return
 }
@@ -45,43 +150,153 @@ func (s *String) Bool() (v bool, err error) {
 // Parse string as a signed 32 bit integer.
 // Returns the parsed value or an error containing positional information.
 func (s *String) Int32() (v int32, err error) {
+	return s.Int32Base(10)
+}
+
+// Parse string as an unsigned 32 bit integer.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Uint32() (v uint32, err error) {
+	return s.Uint32Base(10)
+}
+
+// Parse string as a signed 64 bit integer.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Int64() (v int64, err error) {
+	return s.Int64Base(10)
+}
+
+// Parse string as an unsigned 64 bit integer.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Uint64() (v uint64, err error) {
+	return s.Uint64Base(10)
+}
+
+// Parse string as a signed 32 bit integer in the given base.
+// Base 0 selects the base from the prefix 0x, 0o, 0b or 0 (octal) and allows
+// underscores between digits, as described by strconv.ParseInt.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Int32Base(base int) (v int32, err error) {
 	var t int64
-	if t, err = strconv.ParseInt(s.V, 10, 32); err != nil {
+	if t, err = strconv.ParseInt(s.V, base, 32); err != nil {
 		err = wrapStrconvIntError(err, s)
 	}
 	v = int32(t)
 	return
 }
 
-// Parse string as an unsigned 32 bit integer.
+// Parse string as an unsigned 32 bit integer in the given base.
+// See Int32Base regarding base 0.
 // Returns the parsed value or an error containing positional information.
-func (s *String) Uint32() (v uint32, err error) {
+func (s *String) Uint32Base(base int) (v uint32, err error) {
 	var t uint64
-	if t, err = strconv.ParseUint(s.V, 10, 32); err != nil {
+	if t, err = strconv.ParseUint(s.V, base, 32); err != nil {
 		err = wrapStrconvIntError(err, s)
 	}
 	v = uint32(t)
 	return
 }
 
-// Parse string as a signed 64 bit integer.
+// Parse string as a signed 64 bit integer in the given base.
+// See Int32Base regarding base 0.
 // Returns the parsed value or an error containing positional information.
-func (s *String) Int64() (v int64, err error) {
-	if v, err = strconv.ParseInt(s.V, 10, 64); err != nil {
+func (s *String) Int64Base(base int) (v int64, err error) {
+	if v, err = strconv.ParseInt(s.V, base, 64); err != nil {
 		err = wrapStrconvIntError(err, s)
 	}
 	return
 }
 
-// Parse string as an unsigned 64 bit integer.
+// Parse string as an unsigned 64 bit integer in the given base.
+// See Int32Base regarding base 0.
 // Returns the parsed value or an error containing positional information.
-func (s *String) Uint64() (v uint64, err error) {
-	if v, err = strconv.ParseUint(s.V, 10, 64); err != nil {
+func (s *String) Uint64Base(base int) (v uint64, err error) {
+	if v, err = strconv.ParseUint(s.V, base, 64); err != nil {
 		err = wrapStrconvIntError(err, s)
 	}
 	return
 }
 
+// Parse string as a signed 64 bit integer in the range [min, max].
+// Returns the parsed value or an error containing positional information.
+func (s *String) IntInRange(min, max int64) (v int64, err error) {
+	return s.IntInRangeBase(10, min, max)
+}
+
+// Parse string as a signed 64 bit integer in the given base in the range
+// [min, max]. See Int32Base regarding base 0.
+// Returns the parsed value or an error containing positional information.
+func (s *String) IntInRangeBase(base int, min, max int64) (v int64, err error) {
+	if v, err = s.Int64Base(base); err != nil {
+		return
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("%s: value %d out of range [%d, %d]", s.where(), v, min, max)
+	}
+	return
+}
+
+// Parse string as an unsigned 64 bit integer in the range [min, max].
+// Returns the parsed value or an error containing positional information.
+func (s *String) UintInRange(min, max uint64) (v uint64, err error) {
+	return s.UintInRangeBase(10, min, max)
+}
+
+// Parse string as an unsigned 64 bit integer in the given base in the range
+// [min, max]. See Int32Base regarding base 0.
+// Returns the parsed value or an error containing positional information.
+func (s *String) UintInRangeBase(base int, min, max uint64) (v uint64, err error) {
+	if v, err = s.Uint64Base(base); err != nil {
+		return
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("%s: value %d out of range [%d, %d]", s.where(), v, min, max)
+	}
+	return
+}
+
+// IntRange is an inclusive range of signed integers.
+type IntRange struct {
+	Min, Max int64
+}
+
+// Contains returns true if v is within the range.
+func (r IntRange) Contains(v int64) bool {
+	return v >= r.Min && v <= r.Max
+}
+
+// Parse string as an inclusive range of signed 64 bit integers such as
+// "1000-2000" or "-10--5". A single integer is parsed as a range containing
+// only that integer.
+// Returns the parsed range or an error containing positional information.
+func (s *String) IntRange() (r IntRange, err error) {
+	return s.IntRangeBase(10)
+}
+
+// Parse string as an inclusive range of signed 64 bit integers in the given
+// base such as "0x10-0x1f". See Int32Base regarding base 0.
+// Returns the parsed range or an error containing positional information.
+func (s *String) IntRangeBase(base int) (r IntRange, err error) {
+	lo, hi := s.V, s.V
+	// Skip the sign of the start value when searching for the separator.
+	if i := strings.IndexByte(s.V, '-'); i == 0 {
+		if i = strings.IndexByte(s.V[1:], '-'); i >= 0 {
+			lo, hi = s.V[:i+1], s.V[i+2:]
+		}
+	} else if i > 0 {
+		lo, hi = s.V[:i], s.V[i+1:]
+	}
+	if r.Min, err = strconv.ParseInt(lo, base, 64); err != nil {
+		return IntRange{}, wrapStrconvIntError(err, s)
+	}
+	if r.Max, err = strconv.ParseInt(hi, base, 64); err != nil {
+		return IntRange{}, wrapStrconvIntError(err, s)
+	}
+	if r.Min > r.Max {
+		return IntRange{}, fmt.Errorf("%s: invalid range %s: start greater than end", s.where(), s.V)
+	}
+	return r, nil
+}
+
 // Parse string as a 32 bit floating-point number.
 // Returns the parsed value or an error containing positional information.
 func (s *String) Float32() (v float32, err error) {
@@ -107,7 +322,7 @@ func (s *String) Float64() (v float64, err error) {
 func (s *String) CIDR() (ip net.IP, ipnet *net.IPNet, err error) {
 	if ip, ipnet, err = net.ParseCIDR(s.V); err != nil {
 		// net parse error contain source string
-		err = errors.Wrap(err, s.pos.String())
+		err = errors.Wrap(err, s.where())
 	}
 	return
 }
@@ -116,7 +331,7 @@ func (s *String) CIDR() (ip net.IP, ipnet *net.IPNet, err error) {
 // Returns the parsed value or an error containing positional information.
 func (s *String) IP() (ip net.IP, err error) {
 	if ip = net.ParseIP(s.V); ip == nil {
-		err = fmt.Errorf("%s: invalid IP address: %s", &s.pos, s.V)
+		err = fmt.Errorf("%s: invalid IP address: %s", s.where(), s.V)
 	}
 	return
 }
@@ -126,7 +341,7 @@ func (s *String) IP() (ip net.IP, err error) {
 func (s *String) MAC() (hw net.HardwareAddr, err error) {
 	if hw, err = net.ParseMAC(s.V); err != nil {
 		// override error to make it similar to IP and CIDR parsing errors.
-		err = fmt.Errorf("%s: invalid MAC address: %s", &s.pos, s.V)
+		err = fmt.Errorf("%s: invalid MAC address: %s", s.where(), s.V)
 	}
 	return
 }
@@ -2,6 +2,7 @@ package saft_test
 
 import (
 	"github.com/johan-bolmsjo/saft"
+	"strings"
 	"testing"
 )
 
@@ -146,3 +147,164 @@ func TestString_MAC(t *testing.T) {
 		t.Fatalf(`"x".MAC() = (_, %q); want (_, %q)`, errStr, wantErr)
 	}
 }
+
+func TestString_Form(t *testing.T) {
+	var tbl = []struct {
+		input string
+		want  saft.StringForm
+	}{
+		{`a`, saft.FormSymbol},
+		{`"a"`, saft.FormInterpreted},
+		{Q + `a` + Q, saft.FormRaw},
+	}
+
+	for _, td := range tbl {
+		s, _ := getTestElem(t, td.input).ExpectString()
+		if got := s.Form(); got != td.want {
+			t.Fatalf("%s.Form() = %s; want %s", td.input, got, td.want)
+		}
+	}
+	if got := saft.NewString("a").Form(); got != saft.FormUnspecified {
+		t.Fatalf("saft.NewString().Form() = %s; want %s", got, saft.FormUnspecified)
+	}
+}
+
+func TestString_IntBase(t *testing.T) {
+	var tbl = []struct {
+		input string
+		want  int64
+	}{
+		{"0x1F", 31},
+		{"0o755", 493},
+		{"0755", 493},
+		{"0b101", 5},
+		{"1_000_000", 1000000},
+		{"-42", -42},
+	}
+
+	for _, td := range tbl {
+		v, err := (&saft.String{V: td.input}).Int64Base(0)
+		if v != td.want || err != nil {
+			t.Fatalf(`%q.Int64Base(0) = (%v, %q); want (%v, nil)`, td.input, v, errorString(err), td.want)
+		}
+	}
+
+	v, err := (&saft.String{V: "0o777"}).Uint32Base(0)
+	if v != 0777 || err != nil {
+		t.Fatalf(`"0o777".Uint32Base(0) = (%v, %q); want (511, nil)`, v, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "0x1F"}).Int32Base(10)
+	checkError(t, `"0x1F".Int32Base(10)`, err, `0:0: strconv.ParseInt: parsing "0x1F": invalid syntax`)
+}
+
+func TestString_IntInRange(t *testing.T) {
+	v, err := (&saft.String{V: "80"}).IntInRange(1, 65535)
+	if v != 80 || err != nil {
+		t.Fatalf(`"80".IntInRange(1, 65535) = (%v, %q); want (80, nil)`, v, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "0"}).IntInRange(1, 65535)
+	checkError(t, `"0".IntInRange(1, 65535)`, err, "0:0: value 0 out of range [1, 65535]")
+
+	_, err = (&saft.String{V: "70000"}).UintInRange(1, 65535)
+	checkError(t, `"70000".UintInRange(1, 65535)`, err, "0:0: value 70000 out of range [1, 65535]")
+
+	_, err = (&saft.String{V: "0x1F90"}).IntInRange(1, 65535)
+	checkError(t, `"0x1F90".IntInRange(1, 65535)`, err, `0:0: strconv.ParseInt: parsing "0x1F90": invalid syntax`)
+}
+
+func TestString_IntInRangeBase(t *testing.T) {
+	v, err := (&saft.String{V: "0x1F90"}).IntInRangeBase(0, 1, 65535)
+	if v != 8080 || err != nil {
+		t.Fatalf(`"0x1F90".IntInRangeBase(0, 1, 65535) = (%v, %q); want (8080, nil)`, v, errorString(err))
+	}
+	u, err := (&saft.String{V: "0o17"}).UintInRangeBase(0, 1, 65535)
+	if u != 15 || err != nil {
+		t.Fatalf(`"0o17".UintInRangeBase(0, 1, 65535) = (%v, %q); want (15, nil)`, u, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "0x10000"}).UintInRangeBase(0, 1, 65535)
+	checkError(t, `"0x10000".UintInRangeBase(0, 1, 65535)`, err, "0:0: value 65536 out of range [1, 65535]")
+
+	r, err := (&saft.String{V: "0x10-0x1f"}).IntRangeBase(0)
+	if r != (saft.IntRange{Min: 16, Max: 31}) || err != nil {
+		t.Fatalf(`"0x10-0x1f".IntRangeBase(0) = (%+v, %q); want ({16 31}, nil)`, r, errorString(err))
+	}
+}
+
+func TestString_IntRange(t *testing.T) {
+	var tbl = []struct {
+		input string
+		want  saft.IntRange
+		error string
+	}{
+		{"1000-2000", saft.IntRange{Min: 1000, Max: 2000}, "nil"},
+		{"-10--5", saft.IntRange{Min: -10, Max: -5}, "nil"},
+		{"-10-5", saft.IntRange{Min: -10, Max: 5}, "nil"},
+		{"42", saft.IntRange{Min: 42, Max: 42}, "nil"},
+		{"2000-1000", saft.IntRange{}, "0:0: invalid range 2000-1000: start greater than end"},
+		{"1000-", saft.IntRange{}, `0:0: strconv.ParseInt: parsing "": invalid syntax`},
+	}
+
+	for _, td := range tbl {
+		v, err := (&saft.String{V: td.input}).IntRange()
+		checkError(t, td.input+".IntRange()", err, td.error)
+		if v != td.want {
+			t.Fatalf("%q.IntRange() = %+v; want %+v", td.input, v, td.want)
+		}
+	}
+	if r := (saft.IntRange{Min: 1, Max: 3}); !r.Contains(3) || r.Contains(4) {
+		t.Fatalf("saft.IntRange.Contains() returned unexpected result")
+	}
+}
+
+func TestString_PosAt(t *testing.T) {
+	input := []byte("[" + Q + "a\r\nb\r\nc" + Q + " \"d\\te\"]")
+	want := saft.LexPos{Line: 3, Column: 0, Offset: 8}
+
+	// Positions account for CRs dropped from raw strings.
+	for _, opts := range []saft.ParseOptions{{}, {NormalizeCRLF: true}} {
+		elems, err := saft.ParseBytesWithOptions(input, opts)
+		checkParseError(t, err, "nil")
+		s, _ := elems[0].Index(0).ExpectString()
+		if got := s.PosAt(strings.IndexByte(s.V, 'c')); got != want {
+			t.Fatalf("PosAt() with %+v = %+v; want %+v", opts, got, want)
+		}
+	}
+
+	// Escape sequences occupy two bytes.
+	elems, _ := saft.ParseBytes(input)
+	s, _ := elems[0].Index(1).ExpectString()
+	if got, want := s.PosAt(2), (saft.LexPos{Line: 3, Column: 7, Offset: 15}); got != want {
+		t.Fatalf("PosAt() = %+v; want %+v", got, want)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	src := []byte("a\n\t漢x")
+	var tbl = []struct {
+		opts saft.ParseOptions
+		want int
+	}{
+		{saft.ParseOptions{}, 9},
+		{saft.ParseOptions{Columns: saft.ColumnDisplay}, 10},
+		{saft.ParseOptions{Columns: saft.ColumnDisplay, TabWidth: 4}, 6},
+		{saft.ParseOptions{Columns: saft.ColumnBytes}, 4},
+		{saft.ParseOptions{Columns: saft.ColumnUTF16}, 2},
+	}
+	for _, td := range tbl {
+		if got := saft.Column(src, len(src)-1, td.opts); got != td.want {
+			t.Fatalf("saft.Column() with %+v = %d; want %d", td.opts, got, td.want)
+		}
+	}
+}
+
+func TestNewStringOrigin(t *testing.T) {
+	s := saft.NewStringOrigin("x", "default of field Port")
+	_, err := s.Int64()
+	checkError(t, "Int64()", err, `default of field Port: strconv.ParseInt: parsing "x": invalid syntax`)
+	if got := s.Origin(); got != "default of field Port" {
+		t.Fatalf("Origin() = %q; want %q", got, "default of field Port")
+	}
+}
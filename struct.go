@@ -0,0 +1,73 @@
+package saft
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// structField is a field of a struct mapped to a key in an association list.
+type structField struct {
+	name  string
+	key   string
+	index int
+	typ   reflect.Type
+	opts  string // Tag options following the key
+	doc   string // Documentation from the "saftdoc" tag
+}
+
+// structFields returns the fields of struct type t mapped to keys.
+//
+// The key of a field is given by its "saft" tag, such as `saft:"port"`, or is
+// the field name with its first letter in lower case if the tag has no key.
+// Unexported fields and fields tagged `saft:"-"` are skipped. Options may
+// follow the key separated by commas, such as `saft:"port,default=8080"`.
+//
+// The saftvet analyzer maps fields to keys the same way, see structKeys in
+// package saftvet.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("saft")
+		if tag == "-" {
+			continue
+		}
+		key, opts, _ := strings.Cut(tag, ",")
+		if key == "" {
+			key = lowerFirst(f.Name)
+		}
+		fields = append(fields, structField{
+			name:  f.Name,
+			key:   key,
+			index: i,
+			typ:   f.Type,
+			opts:  opts,
+			doc:   f.Tag.Get("saftdoc"),
+		})
+	}
+	return fields
+}
+
+func lowerFirst(s string) string {
+	for i, r := range s {
+		return string(unicode.ToLower(r)) + s[i+len(string(r)):]
+	}
+	return s
+}
+
+// defaultValue returns the value of the default option. The default option
+// extends to the end of the tag, so it may contain commas but must be the last
+// option.
+func (f *structField) defaultValue() (string, bool) {
+	for opts := f.opts; opts != ""; {
+		if strings.HasPrefix(opts, "default=") {
+			return opts[len("default="):], true
+		}
+		_, opts, _ = strings.Cut(opts, ",")
+	}
+	return "", false
+}
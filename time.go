@@ -0,0 +1,100 @@
+package saft
+
+import (
+	"fmt"
+	"github.com/johan-bolmsjo/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse string as a duration.
+// The format is that of time.ParseDuration extended with the units "d" (24
+// hours) and "w" (7 days), e.g. "1w2d12h".
+// Returns the parsed value or an error containing positional information.
+func (s *String) Duration() (d time.Duration, err error) {
+	if d, err = parseDuration(s.V); err != nil {
+		err = fmt.Errorf("%s: invalid duration: %s", s.where(), s.V)
+	}
+	return
+}
+
+// parseDuration rewrites days and weeks as hours before parsing the duration
+// using the standard library.
+func parseDuration(s string) (time.Duration, error) {
+	if !strings.ContainsAny(s, "dw") {
+		return time.ParseDuration(s)
+	}
+
+	var sb strings.Builder
+	rest := s
+	if rest != "" && (rest[0] == '-' || rest[0] == '+') {
+		sb.WriteByte(rest[0])
+		rest = rest[1:]
+	}
+	for rest != "" {
+		i := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return 0, errors.New("invalid duration")
+		}
+		number := rest[:i]
+		rest = rest[i:]
+
+		j := strings.IndexFunc(rest, func(r rune) bool { return (r >= '0' && r <= '9') || r == '.' })
+		if j < 0 {
+			j = len(rest)
+		}
+		unit := rest[:j]
+		rest = rest[j:]
+
+		var hours float64
+		switch unit {
+		case "d":
+			hours = 24
+		case "w":
+			hours = 7 * 24
+		default:
+			sb.WriteString(number)
+			sb.WriteString(unit)
+			continue
+		}
+		v, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, err
+		}
+		sb.WriteString(strconv.FormatFloat(v*hours, 'f', -1, 64))
+		sb.WriteByte('h')
+	}
+	return time.ParseDuration(sb.String())
+}
+
+// Parse string as a time according to layout as described by time.Parse.
+// The layout time.RFC3339 is used if layout is empty.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Time(layout string) (t time.Time, err error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	if t, err = time.Parse(layout, s.V); err != nil {
+		// time parse error contain source string
+		err = errors.Wrap(err, s.where())
+	}
+	return
+}
+
+// Parse string as a calendar date on the form YYYY-MM-DD.
+// The returned time is midnight UTC of the date.
+// Returns the parsed value or an error containing positional information.
+func (s *String) Date() (t time.Time, err error) {
+	return s.Time("2006-01-02")
+}
+
+// Parse string as a time zone name such as "Europe/Stockholm" or "UTC".
+// Returns the location or an error containing positional information.
+func (s *String) Location() (loc *time.Location, err error) {
+	if loc, err = time.LoadLocation(s.V); err != nil {
+		// time zone error contain source string
+		err = errors.Wrap(err, s.where())
+	}
+	return
+}
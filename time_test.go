@@ -0,0 +1,62 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"testing"
+	"time"
+)
+
+func TestString_Duration(t *testing.T) {
+	var tbl = []struct {
+		input string
+		want  time.Duration
+		error string
+	}{
+		{"1h30m", 90 * time.Minute, "nil"},
+		{"1w2d12h", (9*24 + 12) * time.Hour, "nil"},
+		{"-1.5d", -36 * time.Hour, "nil"},
+		{"0", 0, "nil"},
+		{"1x", 0, "0:0: invalid duration: 1x"},
+		{"d", 0, "0:0: invalid duration: d"},
+	}
+
+	for _, td := range tbl {
+		v, err := (&saft.String{V: td.input}).Duration()
+		checkError(t, td.input+".Duration()", err, td.error)
+		if v != td.want {
+			t.Fatalf("%q.Duration() = %v; want %v", td.input, v, td.want)
+		}
+	}
+}
+
+func TestString_Time(t *testing.T) {
+	const testString = "2024-02-29T12:30:00+01:00"
+	v, err := (&saft.String{V: testString}).Time("")
+	if want := time.Date(2024, 2, 29, 11, 30, 0, 0, time.UTC); !v.Equal(want) || err != nil {
+		t.Fatalf(`%q.Time("") = (%v, %q); want (%v, nil)`, testString, v, errorString(err), want)
+	}
+
+	_, err = (&saft.String{V: "x"}).Time("")
+	checkError(t, `"x".Time("")`, err, `0:0: parsing time "x" as "2006-01-02T15:04:05Z07:00": cannot parse "x" as "2006"`)
+}
+
+func TestString_Date(t *testing.T) {
+	const testString = "2024-02-29"
+	v, err := (&saft.String{V: testString}).Date()
+	if want := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC); !v.Equal(want) || err != nil {
+		t.Fatalf(`%q.Date() = (%v, %q); want (%v, nil)`, testString, v, errorString(err), want)
+	}
+
+	_, err = (&saft.String{V: "2023-02-29"}).Date()
+	checkError(t, `"2023-02-29".Date()`, err, `0:0: parsing time "2023-02-29": day out of range`)
+}
+
+func TestString_Location(t *testing.T) {
+	v, err := (&saft.String{V: "UTC"}).Location()
+	if v != time.UTC || err != nil {
+		t.Fatalf(`"UTC".Location() = (%v, %q); want (UTC, nil)`, v, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "Nowhere/Special"}).Location()
+	checkError(t, `"Nowhere/Special".Location()`, err, "0:0: unknown time zone Nowhere/Special")
+}
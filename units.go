@@ -0,0 +1,138 @@
+package saft
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Byte size multipliers by suffix, matched case insensitively.
+var byteSizeSuffixes = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"p":   1e15,
+	"pb":  1e15,
+	"e":   1e18,
+	"eb":  1e18,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+	"pi":  1 << 50,
+	"pib": 1 << 50,
+	"ei":  1 << 60,
+	"eib": 1 << 60,
+}
+
+// Parse string as a size in bytes such as "64KiB" or "1.5GB".
+// Both SI (kB, MB, GB, ...) and IEC (KiB, MiB, GiB, ...) suffixes are accepted
+// and the "B" may be left out. The value may have a fraction as long as the
+// size is a whole number of bytes.
+// Returns the parsed value or an error containing positional information.
+func (s *String) ByteSize() (v uint64, err error) {
+	i := strings.IndexFunc(s.V, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s.V)
+	}
+	number, suffix := s.V[:i], strings.TrimSpace(s.V[i:])
+
+	mult, ok := byteSizeSuffixes[strings.ToLower(suffix)]
+	if !ok {
+		return 0, fmt.Errorf("%s: invalid byte size suffix %q: %s", s.where(), suffix, s.V)
+	}
+	if _, err = strconv.ParseFloat(number, 64); err != nil {
+		return 0, wrapStrconvFloatError(err, s)
+	}
+
+	var size big.Rat
+	size.SetString(number)
+	size.Mul(&size, new(big.Rat).SetUint64(mult))
+	if !size.IsInt() {
+		return 0, fmt.Errorf("%s: byte size is not a whole number of bytes: %s", s.where(), s.V)
+	}
+	n := size.Num()
+	if !n.IsUint64() {
+		return 0, fmt.Errorf("%s: byte size out of range: %s", s.where(), s.V)
+	}
+	return n.Uint64(), nil
+}
+
+// Parse string as a percentage such as "75%" or "12.5%".
+// Returns the percentage as a fraction (0.75 for "75%") or an error containing
+// positional information.
+func (s *String) Percent() (v float64, err error) {
+	number := strings.TrimSuffix(s.V, "%")
+	if number == s.V {
+		return 0, fmt.Errorf("%s: invalid percentage: %s", s.where(), s.V)
+	}
+	if v, err = strconv.ParseFloat(strings.TrimSpace(number), 64); err != nil {
+		return 0, wrapStrconvFloatError(err, s)
+	}
+	return v / 100, nil
+}
+
+// Units is a registry of unit suffixes and their multipliers used when parsing
+// quantities such as "100req/s" or "2.5krps". The zero value is an empty
+// registry ready for use. Units are safe for concurrent use.
+type Units struct {
+	mu      sync.RWMutex
+	factors map[string]float64
+}
+
+// Register a unit suffix with the factor that quantities having the suffix are
+// multiplied by. Registering the empty suffix allows quantities without unit.
+// A previously registered suffix is replaced.
+func (u *Units) Register(suffix string, factor float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.factors == nil {
+		u.factors = make(map[string]float64)
+	}
+	u.factors[suffix] = factor
+}
+
+// lookup finds the longest registered suffix of s.
+func (u *Units) lookup(s string) (suffix string, factor float64, ok bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	for k, f := range u.factors {
+		if strings.HasSuffix(s, k) && (!ok || len(k) > len(suffix)) {
+			suffix, factor, ok = k, f, true
+		}
+	}
+	return
+}
+
+// Parse string as a quantity with a unit suffix registered in units. Optional
+// whitespace is allowed between the number and the unit.
+// Returns the number multiplied by the unit's factor together with the matched
+// suffix, or an error containing positional information.
+func (s *String) Quantity(units *Units) (v float64, unit string, err error) {
+	unit, factor, ok := units.lookup(s.V)
+	if !ok {
+		return 0, "", fmt.Errorf("%s: unknown unit: %s", s.where(), s.V)
+	}
+	number := strings.TrimSpace(strings.TrimSuffix(s.V, unit))
+	if v, err = strconv.ParseFloat(number, 64); err != nil {
+		return 0, "", wrapStrconvFloatError(err, s)
+	}
+	if v *= factor; math.IsInf(v, 0) {
+		return 0, "", fmt.Errorf("%s: quantity out of range: %s", s.where(), s.V)
+	}
+	return v, unit, nil
+}
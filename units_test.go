@@ -0,0 +1,68 @@
+package saft_test
+
+import (
+	"github.com/johan-bolmsjo/saft"
+	"testing"
+)
+
+func TestString_ByteSize(t *testing.T) {
+	var tbl = []struct {
+		input string
+		want  uint64
+		error string
+	}{
+		{"64KiB", 64 << 10, "nil"},
+		{"1.5GB", 1500000000, "nil"},
+		{"1.5GiB", 3 << 29, "nil"},
+		{"512", 512, "nil"},
+		{"10 mb", 10000000, "nil"},
+		{"16EiB", 0, "0:0: byte size out of range: 16EiB"},
+		{"0.5B", 0, "0:0: byte size is not a whole number of bytes: 0.5B"},
+		{"64XB", 0, `0:0: invalid byte size suffix "XB": 64XB`},
+		{"1.2.3K", 0, `0:0: strconv.ParseFloat: parsing "1.2.3": invalid syntax`},
+	}
+
+	for _, td := range tbl {
+		v, err := (&saft.String{V: td.input}).ByteSize()
+		checkError(t, td.input+".ByteSize()", err, td.error)
+		if v != td.want {
+			t.Fatalf("%q.ByteSize() = %d; want %d", td.input, v, td.want)
+		}
+	}
+}
+
+func TestString_Percent(t *testing.T) {
+	v, err := (&saft.String{V: "75%"}).Percent()
+	if v != 0.75 || err != nil {
+		t.Fatalf(`"75%%".Percent() = (%v, %q); want (0.75, nil)`, v, errorString(err))
+	}
+
+	_, err = (&saft.String{V: "75"}).Percent()
+	checkError(t, `"75".Percent()`, err, "0:0: invalid percentage: 75")
+}
+
+func TestString_Quantity(t *testing.T) {
+	var units saft.Units
+	units.Register("req/s", 1)
+	units.Register("kreq/s", 1000)
+
+	var tbl = []struct {
+		input, unit string
+		want        float64
+		error       string
+	}{
+		{"100req/s", "req/s", 100, "nil"},
+		{"2.5kreq/s", "kreq/s", 2500, "nil"},
+		{"3 req/s", "req/s", 3, "nil"},
+		{"100", "", 0, "0:0: unknown unit: 100"},
+		{"xreq/s", "", 0, `0:0: strconv.ParseFloat: parsing "x": invalid syntax`},
+	}
+
+	for _, td := range tbl {
+		v, unit, err := (&saft.String{V: td.input}).Quantity(&units)
+		checkError(t, td.input+".Quantity()", err, td.error)
+		if v != td.want || unit != td.unit {
+			t.Fatalf("%q.Quantity() = (%v, %q); want (%v, %q)", td.input, v, unit, td.want, td.unit)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package saft
+
+import (
+	"github.com/johan-bolmsjo/errors"
+	"strconv"
+)
+
+// SkipChildren is used as a return value from walk functions to indicate that
+// the children of the visited element are to be skipped.
+var SkipChildren = errors.New("skip children")
+
+// SkipAll is used as a return value from walk functions to indicate that all
+// remaining elements are to be skipped.
+var SkipAll = errors.New("skip all")
+
+// Visitor visits elements in a tree of elements.
+//
+// Enter is called before the children of an element are visited and Leave after.
+// Leave is called even if Enter returned SkipChildren. Both functions may
+// replace the element through the pointer e; children of the replacing element
+// are visited if it's replaced by Enter.
+//
+// The path is the path from the root element to e. A key in the path
+// addresses the first pair with that key, which may not be the pair visited if
+// the association list contains pairs with identical keys. The path is only
+// valid for the duration of the call and must be cloned to be retained.
+type Visitor interface {
+	Enter(path Path, e *Elem) error
+	Leave(path Path, e *Elem) error
+}
+
+// WalkFunc is the type of function called by Walk for each visited element.
+// It has the same semantics as Visitor.Enter.
+type WalkFunc func(path Path, e *Elem) error
+
+// Walk the tree of elements rooted at e in depth first order calling fn for
+// each element. Returns the first error returned by fn except SkipChildren and
+// SkipAll.
+func Walk(e *Elem, fn WalkFunc) error {
+	return WalkVisitor(e, walkFuncVisitor(fn))
+}
+
+// WalkVisitor walks the tree of elements rooted at e in depth first order
+// calling the visitor for each element. Returns the first error returned by
+// the visitor except SkipChildren and SkipAll.
+func WalkVisitor(e *Elem, v Visitor) error {
+	w := walker{v: v}
+	if err := w.walk(e); err != nil && err != SkipAll {
+		return err
+	}
+	return nil
+}
+
+type walker struct {
+	v    Visitor
+	path Path
+}
+
+func (w *walker) walk(e *Elem) error {
+	err := w.v.Enter(w.path, e)
+	if err == nil {
+		err = w.walkChildren(e)
+	} else if err == SkipChildren {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = w.v.Leave(w.path, e); err == SkipChildren {
+		err = nil
+	}
+	return err
+}
+
+func (w *walker) walkChildren(e *Elem) error {
+	switch x := e.any.(type) {
+	case *List:
+		for i := range x.L {
+			if err := w.walkChild(strconv.Itoa(i), &x.L[i]); err != nil {
+				return err
+			}
+		}
+	case *Assoc:
+		for i := range x.L {
+			if err := w.walkChild(x.L[i].K.V, &x.L[i].V); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *walker) walkChild(seg string, e *Elem) error {
+	w.path = append(w.path, seg)
+	err := w.walk(e)
+	w.path = w.path[:len(w.path)-1]
+	return err
+}
+
+type walkFuncVisitor WalkFunc
+
+func (fn walkFuncVisitor) Enter(path Path, e *Elem) error {
+	return fn(path, e)
+}
+
+func (fn walkFuncVisitor) Leave(path Path, e *Elem) error {
+	return nil
+}
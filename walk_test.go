@@ -0,0 +1,89 @@
+package saft_test
+
+import (
+	"fmt"
+	"github.com/johan-bolmsjo/errors"
+	"github.com/johan-bolmsjo/saft"
+	"strings"
+	"testing"
+)
+
+type recordingVisitor struct {
+	sb strings.Builder
+}
+
+func (v *recordingVisitor) Enter(path saft.Path, e *saft.Elem) error {
+	fmt.Fprintf(&v.sb, "enter %q %s\n", path.String(), e.Kind())
+	if e.Kind() == saft.KindAssoc && len(path) > 0 {
+		return saft.SkipChildren
+	}
+	return nil
+}
+
+func (v *recordingVisitor) Leave(path saft.Path, e *saft.Elem) error {
+	fmt.Fprintf(&v.sb, "leave %q %s\n", path.String(), e.Kind())
+	return nil
+}
+
+func TestWalk_Visitor(t *testing.T) {
+	e := getTestElem(t, `{a:[b {c:d}] e:f}`)
+
+	var v recordingVisitor
+	err := saft.WalkVisitor(&e, &v)
+	checkError(t, "saft.WalkVisitor()", err, "nil")
+
+	got := strings.TrimSpace(v.sb.String())
+	want := strings.TrimSpace(`
+enter "" association list
+enter "a" list
+enter "a.0" string
+leave "a.0" string
+enter "a.1" association list
+leave "a.1" association list
+leave "a" list
+enter "e" string
+leave "e" string
+leave "" association list
+`)
+	if got != want {
+		t.Fatalf("visited elements:\n%s\nwant:\n%s\n", got, want)
+	}
+}
+
+func TestWalk_Replace(t *testing.T) {
+	e := getTestElem(t, `[a [b c] {k:a}]`)
+
+	err := saft.Walk(&e, func(path saft.Path, e *saft.Elem) error {
+		if s, ok := e.IsString(); ok && s.V == "a" {
+			*e = saft.ElemOf(saft.NewList(saft.ElemOf(saft.NewString("x"))))
+		}
+		return nil
+	})
+	checkError(t, "saft.Walk()", err, "nil")
+	checkElems(t, []saft.Elem{e}, `[["x" ]["b" "c" ]{"k":["x" ] }]`)
+}
+
+func TestWalk_Stop(t *testing.T) {
+	e := getTestElem(t, `[a b c]`)
+
+	var visited []string
+	stop := func(errStop error) error {
+		visited = nil
+		return saft.Walk(&e, func(path saft.Path, e *saft.Elem) error {
+			visited = append(visited, path.String())
+			if path.String() == "1" {
+				return errStop
+			}
+			return nil
+		})
+	}
+
+	err := stop(saft.SkipAll)
+	checkError(t, "saft.Walk()", err, "nil")
+	if got := strings.Join(visited, ","); got != ",0,1" {
+		t.Fatalf("visited paths %q; want %q", got, ",0,1")
+	}
+
+	err = stop(errors.New("stop"))
+	checkError(t, "saft.Walk()", err, "stop")
+}